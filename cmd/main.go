@@ -3,10 +3,13 @@ package main
 import (
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
 
 	"hot-cofee/internal/config"
 	"hot-cofee/internal/handler"
+	"hot-cofee/internal/service"
 )
 
 func init() {
@@ -16,18 +19,31 @@ func init() {
 }
 
 func main() {
+	slog.SetDefault(slog.New(service.NewLogHandler(slog.NewJSONHandler(os.Stdout, nil))))
 	port := config.GetConfigPort()
-	mux := http.NewServeMux()
+	mux := handler.NewRouter()
 
-	handler.InventoryEndpoints(mux)
-	handler.MenuEndpoints(mux)
-	handler.OrderEndpoints(mux)
-	handler.AggregationEndpoints(mux)
+	handler.VersionEndpoints(mux)
+
+	// The tests of the handler package run the same checks, these only keep a build that skipped
+	// them from serving routes that are undocumented or not audited
+	if err := handler.CheckOpenAPI(mux); err != nil {
+		log.Fatal(err)
+	}
+	if err := handler.CheckAudit(mux); err != nil {
+		log.Fatal(err)
+	}
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		handler.ErrorResponse(w, "405 - No such method", http.StatusMethodNotAllowed)
 	})
 
-	fmt.Println("Server started listening on port -", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), mux))
+	if ok, err := service.HasAPIKeys(); err != nil {
+		log.Fatal(err)
+	} else if !ok {
+		log.Fatal("no API keys issued yet: start with --admin-key to issue the first ones")
+	}
+
+	slog.Info("Server started", "port", port)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), handler.LogRequests(mux, handler.Authenticate(mux, handler.Audit(mux)))))
 }
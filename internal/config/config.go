@@ -7,17 +7,38 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	Port        int
-	Directory   string
-	StoragePath string
+	Port          int
+	Directory     string
+	StoragePath   string
+	TemplatesPath string
+	Shop          ShopInfo
+	// IdempotencyTTL is how long an Idempotency-Key of POST /orders is remembered
+	IdempotencyTTL time.Duration
+	// AdminKey is an API key with the admin role that is not stored, used to issue the first keys
+	AdminKey string
+}
+
+// ShopInfo is printed in the header of every receipt
+type ShopInfo struct {
+	Name    string
+	Address string
+	Phone   string
 }
 
 func ConfigLoad() error {
 	port := flag.Int("port", 8080, "port of srever")
 	directory := flag.String("dir", "data", "data directory")
+	templates := flag.String("templates", "", "directory with receipt.txt.tmpl and receipt.html.tmpl overriding the built-in receipts")
+	shopName := flag.String("shop-name", "Hot Coffee", "shop name printed on receipts")
+	shopAddress := flag.String("shop-address", "", "shop address printed on receipts")
+	shopPhone := flag.String("shop-phone", "", "shop phone printed on receipts")
+	idempotencyTTL := flag.Duration("idempotency-ttl", 24*time.Hour, "how long Idempotency-Key headers of new orders are remembered")
+	// The default is not taken from the environment here, flag.PrintDefaults would print the key
+	adminKey := flag.String("admin-key", "", "API key with the admin role, defaults to $HOT_COFFEE_ADMIN_KEY")
 	help := flag.Bool("help", false, "help")
 
 	flag.Parse()
@@ -26,18 +47,43 @@ func ConfigLoad() error {
 		printHelp()
 		os.Exit(0)
 	}
+	if *adminKey == "" {
+		*adminKey = os.Getenv("HOT_COFFEE_ADMIN_KEY")
+	}
 
 	if err := validatePath(*directory); err != nil {
 		return err
 	}
 
-	storagePath, _ := filepath.Abs(*directory)
+	storagePath, err := filepath.Abs(*directory)
+	if err != nil {
+		return err
+	}
 
 	if *port < 1024 {
 		return errors.New("port couldn't be equal less than 1024")
 	}
 
-	cfg = Config{*port, *directory, storagePath}
+	if *idempotencyTTL <= 0 {
+		return errors.New("idempotency TTL must be positive")
+	}
+
+	templatesPath := ""
+	if *templates != "" {
+		if templatesPath, err = filepath.Abs(*templates); err != nil {
+			return err
+		}
+	}
+
+	cfg = Config{
+		Port:           *port,
+		Directory:      *directory,
+		StoragePath:    storagePath,
+		TemplatesPath:  templatesPath,
+		Shop:           ShopInfo{Name: *shopName, Address: *shopAddress, Phone: *shopPhone},
+		IdempotencyTTL: *idempotencyTTL,
+		AdminKey:       *adminKey,
+	}
 	return cfg.CreateStorage()
 }
 
@@ -49,6 +95,22 @@ func GetConfigPort() int {
 	return cfg.Port
 }
 
+func GetTemplatesPath() string {
+	return cfg.TemplatesPath
+}
+
+func GetShopInfo() ShopInfo {
+	return cfg.Shop
+}
+
+func GetIdempotencyTTL() time.Duration {
+	return cfg.IdempotencyTTL
+}
+
+func GetAdminKey() string {
+	return cfg.AdminKey
+}
+
 var cfg Config
 
 func validatePath(path string) error {
@@ -73,35 +135,41 @@ func printHelp() {
 	fmt.Println(`Coffee Shop Management System
 
 Usage:
-  hot-coffee [--port <N>] [--dir <S>] 
+  hot-coffee [--port <N>] [--dir <S>] [--templates <S>] [--shop-name <S>] [--shop-address <S>] [--shop-phone <S>]
+              [--idempotency-ttl <D>] [--admin-key <S>]
   hot-coffee --help`)
 	fmt.Println("\nOptions:")
 	flag.PrintDefaults() // Prints the default flags' descriptions
 }
 
+// storageFiles lists the JSON files the repositories expect to find in the storage directory
+var storageFiles = []string{
+	"orders.json",
+	"inventory.json",
+	"menu_items.json",
+	"customers.json",
+	"loyalty_program.json",
+	"loyalty_transactions.json",
+	"promotions.json",
+	"taxes.json",
+	"idempotency_keys.json",
+	"api_keys.json",
+	"staff.json",
+	"shifts.json",
+	"audit.jsonl",
+}
+
 func (cfg Config) CreateStorage() error {
 	if _, err := os.Stat(cfg.StoragePath); os.IsNotExist(err) {
 		os.Mkdir(cfg.StoragePath, 0o777)
 	}
-	if _, err := os.Stat(filepath.Join(cfg.StoragePath, "orders.json")); os.IsNotExist(err) {
-		if file, err := os.Create(filepath.Join(cfg.StoragePath, "orders.json")); err != nil {
-			return err
-		} else {
-			defer file.Close()
-		}
-	}
-	if _, err := os.Stat(filepath.Join(cfg.StoragePath, "inventory.json")); os.IsNotExist(err) {
-		if file, err := os.Create(filepath.Join(cfg.StoragePath, "inventory.json")); err != nil {
-			return err
-		} else {
-			defer file.Close()
-		}
-	}
-	if _, err := os.Stat(filepath.Join(cfg.StoragePath, "menu_items.json")); os.IsNotExist(err) {
-		if file, err := os.Create(filepath.Join(cfg.StoragePath, "menu_items.json")); err != nil {
-			return err
-		} else {
-			defer file.Close()
+	for _, name := range storageFiles {
+		if _, err := os.Stat(filepath.Join(cfg.StoragePath, name)); os.IsNotExist(err) {
+			file, err := os.Create(filepath.Join(cfg.StoragePath, name))
+			if err != nil {
+				return err
+			}
+			file.Close()
 		}
 	}
 	return nil
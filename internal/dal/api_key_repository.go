@@ -0,0 +1,23 @@
+package dal
+
+import (
+	repositories "hot-cofee/internal/dal/utils"
+	"hot-cofee/models"
+)
+
+type apiKeyRepo struct{}
+
+// NewAPIKeyRepository creates a new instance of APIKeyRepository
+func NewAPIKeyRepository() repositories.APIKeyRepository {
+	return &apiKeyRepo{}
+}
+
+func (repo *apiKeyRepo) ReadAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := readJSONFile("api_keys.json", "API key", &keys)
+	return keys, err
+}
+
+func (repo *apiKeyRepo) WriteAPIKeys(keys []models.APIKey) error {
+	return writeJSONFile("api_keys.json", "API key", keys)
+}
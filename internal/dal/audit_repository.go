@@ -0,0 +1,33 @@
+package dal
+
+import (
+	"encoding/json"
+
+	repositories "hot-cofee/internal/dal/utils"
+	"hot-cofee/models"
+)
+
+type auditRepo struct{}
+
+// NewAuditRepository creates a new instance of AuditRepository
+func NewAuditRepository() repositories.AuditRepository {
+	return &auditRepo{}
+}
+
+// ReadAuditEntries reads the audit log, stored as one JSON entry per line
+func (repo *auditRepo) ReadAuditEntries() ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+	err := readJSONLines("audit.jsonl", "audit", func(line []byte) error {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+func (repo *auditRepo) AppendAuditEntry(entry models.AuditEntry) error {
+	return appendJSONLine("audit.jsonl", "audit", entry)
+}
@@ -0,0 +1,23 @@
+package dal
+
+import (
+	repositories "hot-cofee/internal/dal/utils"
+	"hot-cofee/models"
+)
+
+type customerRepo struct{}
+
+// NewCustomerRepository creates a new instance of CustomerRepository
+func NewCustomerRepository() repositories.CustomerRepository {
+	return &customerRepo{}
+}
+
+func (repo *customerRepo) ReadCustomers() ([]models.Customer, error) {
+	var customers []models.Customer
+	err := readJSONFile("customers.json", "customer", &customers)
+	return customers, err
+}
+
+func (repo *customerRepo) WriteCustomers(customers []models.Customer) error {
+	return writeJSONFile("customers.json", "customer", customers)
+}
@@ -0,0 +1,23 @@
+package dal
+
+import (
+	repositories "hot-cofee/internal/dal/utils"
+	"hot-cofee/models"
+)
+
+type idempotencyRepo struct{}
+
+// NewIdempotencyRepository creates a new instance of IdempotencyRepository
+func NewIdempotencyRepository() repositories.IdempotencyRepository {
+	return &idempotencyRepo{}
+}
+
+func (repo *idempotencyRepo) ReadKeys() ([]models.IdempotencyKey, error) {
+	var keys []models.IdempotencyKey
+	err := readJSONFile("idempotency_keys.json", "idempotency key", &keys)
+	return keys, err
+}
+
+func (repo *idempotencyRepo) WriteKeys(keys []models.IdempotencyKey) error {
+	return writeJSONFile("idempotency_keys.json", "idempotency key", keys)
+}
@@ -0,0 +1,33 @@
+package dal
+
+import (
+	repositories "hot-cofee/internal/dal/utils"
+	"hot-cofee/models"
+)
+
+type loyaltyRepo struct{}
+
+// NewLoyaltyRepository creates a new instance of LoyaltyRepository
+func NewLoyaltyRepository() repositories.LoyaltyRepository {
+	return &loyaltyRepo{}
+}
+
+func (repo *loyaltyRepo) ReadProgram() (models.LoyaltyProgram, error) {
+	var program models.LoyaltyProgram
+	err := readJSONFile("loyalty_program.json", "loyalty program", &program)
+	return program, err
+}
+
+func (repo *loyaltyRepo) WriteProgram(program models.LoyaltyProgram) error {
+	return writeJSONFile("loyalty_program.json", "loyalty program", program)
+}
+
+func (repo *loyaltyRepo) ReadTransactions() ([]models.LoyaltyTransaction, error) {
+	var transactions []models.LoyaltyTransaction
+	err := readJSONFile("loyalty_transactions.json", "loyalty transaction", &transactions)
+	return transactions, err
+}
+
+func (repo *loyaltyRepo) WriteTransactions(transactions []models.LoyaltyTransaction) error {
+	return writeJSONFile("loyalty_transactions.json", "loyalty transaction", transactions)
+}
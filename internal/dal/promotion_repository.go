@@ -0,0 +1,23 @@
+package dal
+
+import (
+	repositories "hot-cofee/internal/dal/utils"
+	"hot-cofee/models"
+)
+
+type promotionRepo struct{}
+
+// NewPromotionRepository creates a new instance of PromotionRepository
+func NewPromotionRepository() repositories.PromotionRepository {
+	return &promotionRepo{}
+}
+
+func (repo *promotionRepo) ReadPromotions() ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	err := readJSONFile("promotions.json", "promotion", &promotions)
+	return promotions, err
+}
+
+func (repo *promotionRepo) WritePromotions(promotions []models.Promotion) error {
+	return writeJSONFile("promotions.json", "promotion", promotions)
+}
@@ -0,0 +1,33 @@
+package dal
+
+import (
+	repositories "hot-cofee/internal/dal/utils"
+	"hot-cofee/models"
+)
+
+type staffRepo struct{}
+
+// NewStaffRepository creates a new instance of StaffRepository
+func NewStaffRepository() repositories.StaffRepository {
+	return &staffRepo{}
+}
+
+func (repo *staffRepo) ReadStaff() ([]models.Staff, error) {
+	var staff []models.Staff
+	err := readJSONFile("staff.json", "staff", &staff)
+	return staff, err
+}
+
+func (repo *staffRepo) WriteStaff(staff []models.Staff) error {
+	return writeJSONFile("staff.json", "staff", staff)
+}
+
+func (repo *staffRepo) ReadShifts() ([]models.Shift, error) {
+	var shifts []models.Shift
+	err := readJSONFile("shifts.json", "shift", &shifts)
+	return shifts, err
+}
+
+func (repo *staffRepo) WriteShifts(shifts []models.Shift) error {
+	return writeJSONFile("shifts.json", "shift", shifts)
+}
@@ -0,0 +1,104 @@
+package dal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"hot-cofee/internal/config"
+)
+
+// readJSONFile decodes the named file of the storage directory into v.
+// An empty file leaves v untouched.
+func readJSONFile(name, what string, v any) error {
+	file, err := os.OpenFile(filepath.Join(config.GetStoragePath(), name), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return errors.New("unable to open " + what + " file: " + err.Error())
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return errors.New("unable to get file info: " + err.Error())
+	}
+
+	if stat.Size() > 0 {
+		if err := json.NewDecoder(file).Decode(v); err != nil {
+			return errors.New("unable to read " + what + " data: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// writeJSONFile replaces the content of the named file of the storage directory with v
+func writeJSONFile(name, what string, v any) error {
+	file, err := os.OpenFile(filepath.Join(config.GetStoragePath(), name), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return errors.New("unable to open " + what + " file: " + err.Error())
+	}
+	defer file.Close()
+
+	err = file.Truncate(0)
+	if err != nil {
+		return errors.New("unable to truncate " + what + " file: " + err.Error())
+	}
+	_, err = file.Seek(0, 0)
+	if err != nil {
+		return errors.New("unable to seek " + what + " file: " + err.Error())
+	}
+
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return errors.New("unable to format " + what + " data: " + err.Error())
+	}
+	_, err = file.Write(data)
+	if err != nil {
+		return errors.New("unable to write " + what + " data: " + err.Error())
+	}
+	return nil
+}
+
+// readJSONLines decodes every line of the named file of the storage directory with decode
+func readJSONLines(name, what string, decode func(line []byte) error) error {
+	file, err := os.OpenFile(filepath.Join(config.GetStoragePath(), name), os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return errors.New("unable to open " + what + " file: " + err.Error())
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := decode(scanner.Bytes()); err != nil {
+			return errors.New("unable to read " + what + " data: " + err.Error())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.New("unable to read " + what + " file: " + err.Error())
+	}
+	return nil
+}
+
+// appendJSONLine adds v as one line at the end of the named file of the storage directory.
+// The file is opened for appending only, so earlier lines are never rewritten.
+func appendJSONLine(name, what string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.New("unable to format " + what + " data: " + err.Error())
+	}
+	file, err := os.OpenFile(filepath.Join(config.GetStoragePath(), name), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return errors.New("unable to open " + what + " file: " + err.Error())
+	}
+	defer file.Close()
+
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		return errors.New("unable to write " + what + " data: " + err.Error())
+	}
+	return nil
+}
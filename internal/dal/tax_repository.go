@@ -0,0 +1,23 @@
+package dal
+
+import (
+	repositories "hot-cofee/internal/dal/utils"
+	"hot-cofee/models"
+)
+
+type taxRepo struct{}
+
+// NewTaxRepository creates a new instance of TaxRepository
+func NewTaxRepository() repositories.TaxRepository {
+	return &taxRepo{}
+}
+
+func (repo *taxRepo) ReadTaxes() ([]models.TaxRate, error) {
+	var taxes []models.TaxRate
+	err := readJSONFile("taxes.json", "tax", &taxes)
+	return taxes, err
+}
+
+func (repo *taxRepo) WriteTaxes(taxes []models.TaxRate) error {
+	return writeJSONFile("taxes.json", "tax", taxes)
+}
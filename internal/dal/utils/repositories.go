@@ -16,3 +16,47 @@ type OrderRepository interface {
 	ReadOrder() ([]models.Order, error)
 	WriteOrder([]models.Order) error
 }
+
+type CustomerRepository interface {
+	ReadCustomers() ([]models.Customer, error)
+	WriteCustomers([]models.Customer) error
+}
+
+type LoyaltyRepository interface {
+	ReadProgram() (models.LoyaltyProgram, error)
+	WriteProgram(models.LoyaltyProgram) error
+	ReadTransactions() ([]models.LoyaltyTransaction, error)
+	WriteTransactions([]models.LoyaltyTransaction) error
+}
+
+type PromotionRepository interface {
+	ReadPromotions() ([]models.Promotion, error)
+	WritePromotions([]models.Promotion) error
+}
+
+type TaxRepository interface {
+	ReadTaxes() ([]models.TaxRate, error)
+	WriteTaxes([]models.TaxRate) error
+}
+
+type IdempotencyRepository interface {
+	ReadKeys() ([]models.IdempotencyKey, error)
+	WriteKeys([]models.IdempotencyKey) error
+}
+
+type StaffRepository interface {
+	ReadStaff() ([]models.Staff, error)
+	WriteStaff([]models.Staff) error
+	ReadShifts() ([]models.Shift, error)
+	WriteShifts([]models.Shift) error
+}
+
+type APIKeyRepository interface {
+	ReadAPIKeys() ([]models.APIKey, error)
+	WriteAPIKeys([]models.APIKey) error
+}
+
+type AuditRepository interface {
+	ReadAuditEntries() ([]models.AuditEntry, error)
+	AppendAuditEntry(models.AuditEntry) error
+}
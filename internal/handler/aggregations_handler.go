@@ -7,13 +7,25 @@ import (
 	"hot-cofee/internal/service"
 )
 
-func AggregationEndpoints(mux *http.ServeMux) {
+func AggregationEndpoints(mux *Router) {
 	mux.HandleFunc("GET /reports/total-sales", GetTotalSalesHandler)
 	mux.HandleFunc("GET /reports/total-sales/", GetTotalSalesHandler)
 
 	mux.HandleFunc("GET /reports/popular-items", GetPopularItemsHandler)
 	mux.HandleFunc("GET /reports/popular-items/", GetPopularItemsHandler)
 
+	mux.HandleFunc("GET /reports/customer-lifetime-value", GetCustomerLifetimeValueHandler)
+	mux.HandleFunc("GET /reports/customer-lifetime-value/", GetCustomerLifetimeValueHandler)
+
+	mux.HandleFunc("GET /reports/payments-by-method", GetPaymentsByMethodHandler)
+	mux.HandleFunc("GET /reports/payments-by-method/", GetPaymentsByMethodHandler)
+
+	mux.HandleFunc("GET /reports/sales-by-staff", GetSalesByStaffHandler)
+	mux.HandleFunc("GET /reports/sales-by-staff/", GetSalesByStaffHandler)
+
+	mux.HandleFunc("GET /reports/sales-by-shift", GetSalesByShiftHandler)
+	mux.HandleFunc("GET /reports/sales-by-shift/", GetSalesByShiftHandler)
+
 	// mux.HandleFunc("GET /reports/popular-items/{id}", GetPopularItemsByNumHandler)
 }
 
@@ -24,7 +36,7 @@ func AggregationEndpoints(mux *http.ServeMux) {
 func GetTotalSalesHandler(w http.ResponseWriter, r *http.Request) {
 	totalSales, err := service.GetTotalSales()
 	if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -47,7 +59,7 @@ func GetTotalSalesHandler(w http.ResponseWriter, r *http.Request) {
 func GetPopularItemsHandler(w http.ResponseWriter, r *http.Request) {
 	popularItems, err := service.GetPopularItems()
 	if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -66,3 +78,53 @@ func GetPopularItemsHandler(w http.ResponseWriter, r *http.Request) {
 		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
 	}
 }
+
+func GetCustomerLifetimeValueHandler(w http.ResponseWriter, r *http.Request) {
+	values, err := service.GetCustomerLifetimeValues()
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	// Marshal the items with indentation
+	jsonData, err := json.MarshalIndent(values, "", "    ")
+	if err != nil {
+		ErrorResponse(w, "Failed to encode customer lifetime values", http.StatusInternalServerError)
+		return
+	}
+
+	// Write the indented JSON to the response
+	if _, err = w.Write(jsonData); err != nil {
+		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+	}
+}
+
+func GetPaymentsByMethodHandler(w http.ResponseWriter, r *http.Request) {
+	summaries, err := service.GetPaymentsByMethod()
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, summaries)
+}
+
+func GetSalesByStaffHandler(w http.ResponseWriter, r *http.Request) {
+	sales, err := service.GetSalesByStaff()
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, sales)
+}
+
+func GetSalesByShiftHandler(w http.ResponseWriter, r *http.Request) {
+	sales, err := service.GetSalesByShift()
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, sales)
+}
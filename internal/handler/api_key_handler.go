@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+func APIKeyEndpoints(mux *Router) {
+	mux.HandleFunc("POST /api-keys", PostAPIKeyHandler)
+	mux.HandleFunc("POST /api-keys/", PostAPIKeyHandler)
+
+	mux.HandleFunc("GET /api-keys", GetAllAPIKeysHandler)
+	mux.HandleFunc("GET /api-keys/", GetAllAPIKeysHandler)
+
+	mux.HandleFunc("GET /api-keys/{id}", GetAPIKeyByIDHandler)
+	mux.HandleFunc("GET /api-keys/{id}/", GetAPIKeyByIDHandler)
+
+	mux.HandleFunc("DELETE /api-keys/{id}", DeleteAPIKeyHandler)
+	mux.HandleFunc("DELETE /api-keys/{id}/", DeleteAPIKeyHandler)
+}
+
+func GetAllAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := service.GetAllAPIKeys()
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, keys)
+	slog.InfoContext(r.Context(), "Retrieved all API keys")
+}
+
+func GetAPIKeyByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	key, err := service.GetAPIKeyByID(id)
+	if errors.Is(err, service.ErrAPIKeyNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, key)
+	slog.InfoContext(r.Context(), "Retrieved API key", "ID", id)
+}
+
+// PostAPIKeyHandler issues a key for {"name": "...", "role": "barista" | "manager" | "admin" | "metrics"}
+// or for {"staff_id": 1}.
+// The key is only part of this response.
+func PostAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		ServiceError(w, ErrUnsupportedContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	var request models.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		ErrorResponse(w, "invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	issued, err := service.IssueAPIKey(r.Context(), request.Name, request.Role, request.StaffID)
+	if errors.Is(err, service.ErrAPIKeyNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeCreated(w, r, "/api-keys/"+url.PathEscape(issued.ID), issued)
+	slog.InfoContext(r.Context(), "Issued API key", "ID", issued.ID, "role", issued.Role)
+}
+
+func DeleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := service.RevokeAPIKey(r.Context(), id); err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	slog.InfoContext(r.Context(), "Revoked API key", "ID", id)
+}
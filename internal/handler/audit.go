@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+// auditRoute names the entity a changing route changes and the action of the audit entry the
+// service layer records for it
+type auditRoute struct {
+	Entity string
+	Action string
+}
+
+// auditRoutes lists every route that changes data. The entries themselves are recorded by the
+// service layer while it holds the lock of the changed data; CheckAudit refuses routes missing
+// here, so that a new route is not added without deciding what it records.
+var auditRoutes = map[string]auditRoute{
+	"POST /orders":                                 {Entity: "order", Action: "create"},
+	"PUT /orders/{id}":                             {Entity: "order", Action: "update"},
+	"PATCH /orders/{id}":                           {Entity: "order", Action: "update"},
+	"DELETE /orders/{id}":                          {Entity: "order", Action: "delete"},
+	"POST /orders/{id}/close":                      {Entity: "order", Action: "close"},
+	"POST /orders/{id}/payments":                   {Entity: "order", Action: "pay"},
+	"POST /orders/{id}/refunds":                    {Entity: "order", Action: "refund"},
+	"PUT /orders/{id}/items/{product_id}/status":   {Entity: "order", Action: "set_item_status"},
+	"POST /orders/{id}/items/{product_id}/advance": {Entity: "order", Action: "advance_item"},
+
+	"POST /menu":              {Entity: "menu_item", Action: "create"},
+	"PUT /menu/{id}":          {Entity: "menu_item", Action: "update"},
+	"PATCH /menu/{id}":        {Entity: "menu_item", Action: "update"},
+	"DELETE /menu/{id}":       {Entity: "menu_item", Action: "archive"},
+	"POST /menu/{id}/restore": {Entity: "menu_item", Action: "restore"},
+	"POST /menu/import":       {Entity: "menu_item", Action: "import"},
+
+	"POST /inventory":              {Entity: "inventory_item", Action: "create"},
+	"PUT /inventory/{id}":          {Entity: "inventory_item", Action: "update"},
+	"PATCH /inventory/{id}":        {Entity: "inventory_item", Action: "update"},
+	"DELETE /inventory/{id}":       {Entity: "inventory_item", Action: "archive"},
+	"POST /inventory/{id}/restore": {Entity: "inventory_item", Action: "restore"},
+	"POST /inventory/import":       {Entity: "inventory_item", Action: "import"},
+
+	"POST /customers":        {Entity: "customer", Action: "create"},
+	"PUT /customers/{id}":    {Entity: "customer", Action: "update"},
+	"DELETE /customers/{id}": {Entity: "customer", Action: "delete"},
+
+	"PUT /loyalty/program": {Entity: "loyalty_program", Action: "update"},
+
+	"POST /promotions":        {Entity: "promotion", Action: "create"},
+	"PUT /promotions/{id}":    {Entity: "promotion", Action: "update"},
+	"DELETE /promotions/{id}": {Entity: "promotion", Action: "delete"},
+
+	"POST /taxes":        {Entity: "tax", Action: "create"},
+	"PUT /taxes/{id}":    {Entity: "tax", Action: "update"},
+	"DELETE /taxes/{id}": {Entity: "tax", Action: "delete"},
+
+	"POST /staff":                {Entity: "staff", Action: "create"},
+	"PUT /staff/{id}":            {Entity: "staff", Action: "update"},
+	"DELETE /staff/{id}":         {Entity: "staff", Action: "delete"},
+	"POST /staff/{id}/clock-in":  {Entity: "staff", Action: "clock_in"},
+	"POST /staff/{id}/clock-out": {Entity: "staff", Action: "clock_out"},
+
+	"POST /api-keys":        {Entity: "api_key", Action: "create"},
+	"DELETE /api-keys/{id}": {Entity: "api_key", Action: "delete"},
+}
+
+func AuditEndpoints(mux *Router) {
+	mux.HandleFunc("GET /audit", GetAuditHandler)
+	mux.HandleFunc("GET /audit/", GetAuditHandler)
+}
+
+// GetAuditHandler lists the audit log, newest first, filtered by the query parameters
+func GetAuditHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	entries, total, err := service.GetAuditEntries(filter)
+	if errors.Is(err, service.ErrAuditNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, r, http.StatusOK, entries)
+	slog.InfoContext(r.Context(), "Retrieved audit log")
+}
+
+// Audit puts the actor of every request in its context, so that the service layer records the
+// changes made while serving it, both the one requested and those following from it, like
+// deducting stock or crediting loyalty points, for them.
+func Audit(mux *Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := principalFrom(r.Context())
+		actor := models.AuditActor{
+			KeyID:   principal.ID,
+			Name:    principal.Name,
+			Role:    principal.Role,
+			StaffID: principal.StaffID,
+		}
+		mux.ServeHTTP(w, r.WithContext(service.WithAuditActor(r.Context(), actor)))
+	})
+}
+
+// CheckAudit reports the registered routes that change data without an entry in auditRoutes
+// and the entries whose route is not registered
+func CheckAudit(mux *Router) error {
+	registered := make(map[string]bool)
+	var errs []error
+	for _, route := range mux.Routes() {
+		route = unversionedRoute(route)
+		registered[route] = true
+		if _, audited := auditRoutes[route]; !audited && !strings.HasPrefix(route, "GET ") {
+			errs = append(errs, fmt.Errorf("route %s changes data but is not audited", route))
+		}
+	}
+	for route := range auditRoutes {
+		if !registered[route] {
+			errs = append(errs, fmt.Errorf("audited route %s is not registered", route))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func parseAuditFilter(r *http.Request) (models.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Entity:    query.Get("entity"),
+		EntityID:  query.Get("entity_id"),
+		Action:    query.Get("action"),
+		KeyID:     query.Get("key_id"),
+		RequestID: query.Get("request_id"),
+	}
+	var err error
+	if staffID := query.Get("staff_id"); staffID != "" {
+		if filter.StaffID, err = strconv.Atoi(staffID); err != nil {
+			return filter, fmt.Errorf("staff_id is not an integer")
+		}
+	}
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = parseOrderTime(from, false); err != nil {
+			return filter, fmt.Errorf("invalid from: %s", from)
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = parseOrderTime(to, true); err != nil {
+			return filter, fmt.Errorf("invalid to: %s", to)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return filter, fmt.Errorf("limit is not an integer")
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil {
+			return filter, fmt.Errorf("offset is not an integer")
+		}
+	}
+	return filter, nil
+}
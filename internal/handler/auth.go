@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// publicRoutes are answered without an API key
+var publicRoutes = map[string]bool{
+	"GET /openapi.json": true,
+}
+
+// routeRoles is the least role allowed to call a route. Routes not listed here can be read
+// by every role and changed by managers: baristas take, change, pay and close orders and
+// keep customer profiles and clock themselves in and out, but do not edit the menu, prices, stock or refunds.
+var routeRoles = map[string]string{
+	"POST /orders":                                 service.RoleBarista,
+	"PUT /orders/{id}":                             service.RoleBarista,
+	"PATCH /orders/{id}":                           service.RoleBarista,
+	"POST /orders/{id}/close":                      service.RoleBarista,
+	"POST /orders/{id}/payments":                   service.RoleBarista,
+	"PUT /orders/{id}/items/{product_id}/status":   service.RoleBarista,
+	"POST /orders/{id}/items/{product_id}/advance": service.RoleBarista,
+	"POST /customers":                              service.RoleBarista,
+	"PUT /customers/{id}":                          service.RoleBarista,
+	"POST /staff/{id}/clock-in":                    service.RoleBarista,
+	"POST /staff/{id}/clock-out":                   service.RoleBarista,
+
+	"GET /menu/export":                     service.RoleManager,
+	"GET /inventory/export":                service.RoleManager,
+	"GET /reports/total-sales":             service.RoleManager,
+	"GET /reports/popular-items":           service.RoleManager,
+	"GET /reports/customer-lifetime-value": service.RoleManager,
+	"GET /reports/payments-by-method":      service.RoleManager,
+	"GET /reports/sales-by-staff":          service.RoleManager,
+	"GET /reports/sales-by-shift":          service.RoleManager,
+	"GET /audit":                           service.RoleManager,
+	"GET /metrics":                         service.RoleMetrics,
+
+	"POST /api-keys":        service.RoleAdmin,
+	"GET /api-keys":         service.RoleAdmin,
+	"GET /api-keys/{id}":    service.RoleAdmin,
+	"DELETE /api-keys/{id}": service.RoleAdmin,
+}
+
+// requiredRole returns the least role allowed to call the route, "" for public routes
+func requiredRole(route string) string {
+	if publicRoutes[route] {
+		return ""
+	}
+	if role, ok := routeRoles[route]; ok {
+		return role
+	}
+	if strings.HasPrefix(route, "GET ") {
+		return service.RoleBarista
+	}
+	return service.RoleManager
+}
+
+// Authenticate wraps the handler of the router so that every request needs an API key, sent as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>", whose role allows the matched route.
+// Browsers cannot set headers on an EventSource, so GET /queue/events also takes ?api_key=.
+func Authenticate(mux *Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		route := unversionedRoute(pattern)
+		required := requiredRole(route)
+		if required == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get("X-API-Key")
+		if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+			key = strings.TrimSpace(token)
+		}
+		if key == "" && route == "GET /queue/events" {
+			key = r.URL.Query().Get("api_key")
+		}
+		principal, err := service.AuthenticateAPIKey(key)
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="hot-coffee"`)
+			ServiceError(w, err, http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			ServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if !service.RoleAllows(principal.Role, required) {
+			ErrorResponse(w, "the "+principal.Role+" role is not allowed to "+route, http.StatusForbidden)
+			return
+		}
+		slog.DebugContext(r.Context(), "Authenticated request", "key", principal.ID, "role", principal.Role, "route", route)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey, principal)))
+	})
+}
+
+// principalFrom returns the API key a request was authenticated with
+func principalFrom(ctx context.Context) (models.APIKey, bool) {
+	principal, ok := ctx.Value(principalKey).(models.APIKey)
+	return principal, ok
+}
+
+// staffIDFrom returns the member of staff the API key of a request was issued to, or 0
+func staffIDFrom(r *http.Request) int {
+	principal, _ := principalFrom(r.Context())
+	return principal.StaffID
+}
+
+// unversionedRoute turns a matched pattern such as "GET /v1/orders/{id}/{$}" into "GET /orders/{id}".
+// Patterns without a method, like the catch-all, are returned as they are.
+func unversionedRoute(pattern string) string {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		return pattern
+	}
+	path = strings.TrimSuffix(path, "{$}")
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	for _, version := range APIVersions {
+		if version.Prefix != "" && strings.HasPrefix(path, version.Prefix+"/") {
+			path = strings.TrimPrefix(path, version.Prefix)
+			break
+		}
+	}
+	return method + " " + path
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+var CustomerService = service.NewCustomerService()
+
+func CustomerEndpoints(mux *Router) {
+	mux.HandleFunc("POST /customers", PostCustomerHandler)
+	mux.HandleFunc("POST /customers/", PostCustomerHandler)
+
+	mux.HandleFunc("GET /customers", GetAllCustomersHandler)
+	mux.HandleFunc("GET /customers/", GetAllCustomersHandler)
+
+	mux.HandleFunc("GET /customers/{id}", GetCustomerByIDHandler)
+	mux.HandleFunc("GET /customers/{id}/", GetCustomerByIDHandler)
+
+	mux.HandleFunc("PUT /customers/{id}", PutCustomerHandler)
+	mux.HandleFunc("PUT /customers/{id}/", PutCustomerHandler)
+
+	mux.HandleFunc("DELETE /customers/{id}", DeleteCustomerByIDHandler)
+	mux.HandleFunc("DELETE /customers/{id}/", DeleteCustomerByIDHandler)
+
+	mux.HandleFunc("GET /customers/{id}/orders", GetCustomerOrdersHandler)
+	mux.HandleFunc("GET /customers/{id}/orders/", GetCustomerOrdersHandler)
+}
+
+func GetAllCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	customers, err := CustomerService.GetAllCustomers()
+	if err != nil {
+		ErrorResponse(w, "Could not retrieve customers data", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, customers)
+	slog.InfoContext(r.Context(), "Retrieved all customers")
+}
+
+func GetCustomerByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+	customer, err := CustomerService.GetCustomerByID(ID)
+	if errors.Is(err, service.ErrCustomerNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, customer)
+	slog.InfoContext(r.Context(), "Retrieved customer", "ID", ID)
+}
+
+func GetCustomerOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := CustomerService.GetCustomerByID(ID); errors.Is(err, service.ErrCustomerNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	orders, _, err := OrderService.GetOrders(models.OrderFilter{CustomerID: ID, Sort: "created_at"})
+	if err != nil {
+		ErrorResponse(w, "Could not retrieve orders data", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, orders)
+	slog.InfoContext(r.Context(), "Retrieved customer orders", "ID", ID)
+}
+
+func PostCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	customer, err := parseCustomer(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	customer, err = CustomerService.AddNewCustomer(r.Context(), customer)
+	if errors.Is(err, service.ErrCustomerNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeCreated(w, r, fmt.Sprintf("/customers/%d", customer.ID), customer)
+	slog.InfoContext(r.Context(), "Added customer", "ID", customer.ID)
+}
+
+func PutCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	customer, err := parseCustomer(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+	if customer.ID != 0 && customer.ID != ID {
+		ErrorResponse(w, "customer ID does not match id", http.StatusBadRequest)
+		return
+	}
+	customer.ID = ID
+
+	if err = CustomerService.ModifyCustomer(r.Context(), customer); err != nil && !errors.Is(err, service.ErrNothingToModify) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	customer, err = CustomerService.GetCustomerByID(ID)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, customer)
+	slog.InfoContext(r.Context(), "Updated customer", "ID", ID)
+}
+
+func DeleteCustomerByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+	err = CustomerService.DeleteCustomer(r.Context(), ID)
+	if errors.Is(err, service.ErrCustomerHasOrders) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	slog.InfoContext(r.Context(), "Deleted customer", "ID", ID)
+}
+
+func parseCustomer(r *http.Request) (models.Customer, error) {
+	var customer models.Customer
+	contentType := r.Header.Get("Content-Type")
+
+	if contentType == "application/json" {
+		// Parse JSON payload
+		if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
+			return customer, fmt.Errorf("invalid JSON payload")
+		}
+	} else if contentType == "application/x-www-form-urlencoded" {
+		// Parse form data
+		if err := r.ParseForm(); err != nil {
+			return customer, fmt.Errorf("invalid form data")
+		}
+		customer = models.Customer{
+			Name:  r.FormValue("name"),
+			Phone: r.FormValue("phone"),
+			Email: r.FormValue("email"),
+			Notes: r.FormValue("notes"),
+		}
+	} else {
+		return customer, ErrUnsupportedContentType
+	}
+
+	return customer, nil
+}
@@ -3,25 +3,86 @@ package handler
 import (
 	"encoding/json"
 	"errors"
-	"log/slog"
 	"net/http"
+	"strings"
+
+	"hot-cofee/internal/service"
 )
 
 var ErrUnsupportedContentType = errors.New("unsupported content type")
 
-type ErrorJson struct {
-	Error string `json:"error"`
+// problemTypePrefix makes the stable error code a URI for the type member of a problem
+const problemTypePrefix = "urn:hot-coffee:error:"
+
+// Problem is the body of every error response, a problem details object (RFC 7807).
+// Error repeats the detail for clients of the former {"error": "..."} body.
+type Problem struct {
+	Type   string               `json:"type"`
+	Title  string               `json:"title"`
+	Status int                  `json:"status"`
+	Detail string               `json:"detail"`
+	Code   string               `json:"code"`
+	Fields []service.FieldError `json:"fields,omitempty"`
+	Error  string               `json:"error"`
+}
+
+// statusByCode maps the error codes of the service layer to response statuses
+var statusByCode = map[string]int{
+	service.CodeValidation:          http.StatusBadRequest,
+	service.CodeNotFound:            http.StatusNotFound,
+	service.CodeConflict:            http.StatusConflict,
+	service.CodeInsufficientStock:   http.StatusConflict,
+	service.CodeInsufficientBalance: http.StatusConflict,
+	service.CodeOrderClosed:         http.StatusConflict,
+	service.CodeOrderNotClosed:      http.StatusConflict,
+	service.CodeOrderNotPaid:        http.StatusConflict,
+	service.CodeOrderHasPayments:    http.StatusConflict,
+	service.CodeCustomerHasOrders:   http.StatusConflict,
+	service.CodeIdempotencyKeyUsed:  http.StatusUnprocessableEntity,
+	service.CodeInvalidAPIKey:       http.StatusUnauthorized,
+	service.CodePreconditionFailed:  http.StatusPreconditionFailed,
+	service.CodeStorage:             http.StatusInternalServerError,
 }
 
+// ErrorResponse answers with a problem whose code is derived from the status, e.g. bad_request
 func ErrorResponse(w http.ResponseWriter, msg string, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	errorResponse := ErrorJson{Error: msg}
-	jsonResponse, err := json.Marshal(errorResponse)
+	writeProblem(w, Problem{Status: statusCode, Detail: msg})
+}
+
+// ServiceError answers with the code, status and invalid fields of a service error.
+// The given status is only used for errors whose code is not in statusByCode.
+func ServiceError(w http.ResponseWriter, err error, statusCode int) {
+	var mismatch *service.VersionMismatch
+	if errors.As(err, &mismatch) {
+		w.Header().Set("ETag", etag(mismatch.Current))
+	}
+	code := service.ErrorCode(err)
+	if status, ok := statusByCode[code]; ok {
+		statusCode = status
+	}
+	writeProblem(w, Problem{
+		Status: statusCode,
+		Detail: err.Error(),
+		Code:   code,
+		Fields: service.ErrorFields(err),
+	})
+}
+
+func writeProblem(w http.ResponseWriter, problem Problem) {
+	if problem.Code == "" {
+		problem.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(problem.Status)), " ", "_")
+	}
+	problem.Type = problemTypePrefix + problem.Code
+	problem.Title = http.StatusText(problem.Status)
+	problem.Error = problem.Detail
+
+	jsonResponse, err := json.MarshalIndent(problem, "", "    ")
 	if err != nil {
 		http.Error(w, "Failed to generate error response", http.StatusInternalServerError)
 		return
 	}
-	slog.Error(msg)
+	noteProblem(w, problem)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
 	w.Write(jsonResponse)
 }
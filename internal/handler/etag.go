@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-cofee/internal/service"
+)
+
+// noVersion is expected by an If-Match header that names no version, it matches no resource
+const noVersion = -2
+
+// etag formats the version of a resource as a strong entity tag
+func etag(version int) string {
+	return fmt.Sprintf("%q", fmt.Sprint(version))
+}
+
+// expectedVersion returns the version the If-Match header of the request expects the resource
+// to have, for the service to check in the same step as the change: service.AnyVersion without
+// the header or for "*", current if it is one of the listed tags and otherwise a version the
+// resource does not have, so that the change fails with 412.
+func expectedVersion(r *http.Request, current int) int {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return service.AnyVersion
+	}
+	expected := noVersion
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" {
+			return service.AnyVersion
+		}
+		version, err := strconv.Unquote(tag)
+		if err != nil {
+			continue
+		}
+		if n, err := strconv.Atoi(version); err == nil && n >= 0 {
+			if n == current {
+				return n
+			} else if expected == noVersion {
+				expected = n
+			}
+		}
+	}
+	return expected
+}
+
+// patchVersion is the version a patch computed on the current resource is saved against, so that
+// a change made in between fails instead of being overwritten
+func patchVersion(r *http.Request, current int) int {
+	if expected := expectedVersion(r, current); expected != service.AnyVersion {
+		return expected
+	}
+	return current
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+// maxIdempotencyKeyLength keeps clients from using whole payloads as keys
+const maxIdempotencyKeyLength = 255
+
+// idempotencyScope is an Idempotency-Key as sent by one API key
+type idempotencyScope struct {
+	principal string
+	key       string
+}
+
+// idempotencyLock serializes the requests with one key, so that a retry arriving while the
+// first attempt is still running waits for its result instead of creating a second order.
+// Users counts the requests holding or waiting for it, so that it is dropped with the last one.
+type idempotencyLock struct {
+	sync.Mutex
+	users int
+}
+
+var (
+	idempotencyLocksMu sync.Mutex
+	idempotencyLocks   = map[idempotencyScope]*idempotencyLock{}
+)
+
+// lockIdempotencyKey locks the key for the request and returns the function unlocking it.
+// Requests with other keys are not held up.
+func lockIdempotencyKey(scope idempotencyScope) func() {
+	idempotencyLocksMu.Lock()
+	lock, exists := idempotencyLocks[scope]
+	if !exists {
+		lock = &idempotencyLock{}
+		idempotencyLocks[scope] = lock
+	}
+	lock.users++
+	idempotencyLocksMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		idempotencyLocksMu.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(idempotencyLocks, scope)
+		}
+		idempotencyLocksMu.Unlock()
+	}
+}
+
+// responseRecorder passes the response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	rec.status = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	rec.body.Write(data)
+	return rec.ResponseWriter.Write(data)
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// withIdempotencyKey replays the stored response when a request is sent again with the same
+// Idempotency-Key header by the same API key. Only successful responses are stored, so a failed
+// request can be retried.
+func withIdempotencyKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			ErrorResponse(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			ErrorResponse(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(r.Header.Get("Content-Type")+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		principal, _ := principalFrom(r.Context())
+		defer lockIdempotencyKey(idempotencyScope{principal: principal.ID, key: key})()
+
+		record, found, err := service.LookupIdempotencyKey(principal.ID, key, requestHash)
+		if errors.Is(err, service.ErrIdempotencyKeyReused) {
+			ServiceError(w, err, http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			ServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if found {
+			var response bytes.Buffer
+			if err := json.Indent(&response, record.Response, "", "    "); err != nil {
+				response.Write(record.Response)
+			}
+			w.Header().Set("Content-Type", record.ContentType)
+			if record.Location != "" {
+				w.Header().Set("Location", record.Location)
+			}
+			if record.ETag != "" {
+				w.Header().Set("ETag", record.ETag)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.Status)
+			if _, err := w.Write(response.Bytes()); err != nil {
+				slog.ErrorContext(r.Context(), "Failed to write response", "error", err)
+			}
+			slog.InfoContext(r.Context(), "Replayed request", "idempotency_key", key, "order", record.OrderID)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		if rec.status < 200 || rec.status >= 300 || !json.Valid(rec.body.Bytes()) {
+			return
+		}
+		var created struct {
+			ID int `json:"order_id"`
+		}
+		json.Unmarshal(rec.body.Bytes(), &created)
+		record = models.IdempotencyKey{
+			Principal:   principal.ID,
+			Key:         key,
+			RequestHash: requestHash,
+			OrderID:     created.ID,
+			Status:      rec.status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Location:    rec.Header().Get("Location"),
+			ETag:        rec.Header().Get("ETag"),
+			Response:    json.RawMessage(bytes.Clone(rec.body.Bytes())),
+		}
+		if err := service.SaveIdempotencyKey(record); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save idempotency key", "idempotency_key", key, "error", err)
+		}
+	}
+}
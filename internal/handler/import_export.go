@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+var (
+	menuCSVHeader      = []string{"product_id", "name", "description", "category", "price", "prep_seconds", "ingredients"}
+	inventoryCSVHeader = []string{"ingredient_id", "name", "quantity", "unit"}
+)
+
+// parseImportOptions reads the mode (upsert or replace) and dry_run query parameters of an import
+func parseImportOptions(r *http.Request) (string, bool, error) {
+	query := r.URL.Query()
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = service.ImportUpsert
+	} else if mode != service.ImportUpsert && mode != service.ImportReplace {
+		return "", false, fmt.Errorf("%w: %q", service.ErrInvalidImportMode, mode)
+	}
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return "", false, fmt.Errorf("dry_run is not a boolean")
+		}
+	}
+	return mode, dryRun, nil
+}
+
+// parseIncludeArchived reads the include_archived query parameter of a listing or export
+func parseIncludeArchived(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("include_archived")
+	if value == "" {
+		return false, nil
+	}
+	includeArchived, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("include_archived is not a boolean")
+	}
+	return includeArchived, nil
+}
+
+// importMediaType tells whether the import body is a JSON array or CSV
+func importMediaType(r *http.Request) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/json" && mediaType != "text/csv") {
+		return "", ErrUnsupportedContentType
+	}
+	return mediaType, nil
+}
+
+// readJSONRows splits a JSON array into its elements so that every row can fail on its own
+func readJSONRows(body io.Reader) ([]json.RawMessage, error) {
+	var rows []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("invalid JSON payload, expected an array: %v", err)
+	}
+	return rows, nil
+}
+
+// readCSVRows reads a CSV body whose first line names the columns and returns every
+// following row as a map from column name to value
+func readCSVRows(body io.Reader, required []string) ([]map[string]string, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %v", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	for _, column := range required {
+		if !containsColumn(header, column) {
+			return nil, fmt.Errorf("CSV header is missing the %s column", column)
+		}
+	}
+	reader.FieldsPerRecord = len(header)
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[column] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func containsColumn(header []string, column string) bool {
+	for _, val := range header {
+		if val == column {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCSVFloat(row map[string]string, column string) (float64, error) {
+	if row[column] == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(row[column], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a float", column)
+	}
+	return value, nil
+}
+
+func formatCSVFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// parseMenuImport decodes the menu items of an import. Rows that cannot be decoded are
+// reported as import errors; the error is only returned when the body as a whole is unusable.
+func parseMenuImport(r *http.Request) ([]models.MenuItem, []models.ImportError, error) {
+	mediaType, err := importMediaType(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var items []models.MenuItem
+	var rowErrors []models.ImportError
+	if mediaType == "application/json" {
+		rows, err := readJSONRows(r.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, row := range rows {
+			var item models.MenuItem
+			if err := json.Unmarshal(row, &item); err != nil {
+				rowErrors = append(rowErrors, models.ImportError{Row: i + 1, Error: err.Error()})
+			}
+			items = append(items, item)
+		}
+		return items, rowErrors, nil
+	}
+
+	rows, err := readCSVRows(r.Body, []string{"product_id", "name", "price", "ingredients"})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, row := range rows {
+		item, err := menuItemFromCSV(row)
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportError{Row: i + 1, ID: row["product_id"], Error: err.Error()})
+		}
+		items = append(items, item)
+	}
+	return items, rowErrors, nil
+}
+
+// menuItemFromCSV reads a CSV row; ingredients are written as ingredient_id:quantity pairs separated by ";"
+func menuItemFromCSV(row map[string]string) (models.MenuItem, error) {
+	item := models.MenuItem{
+		ID:          row["product_id"],
+		Name:        row["name"],
+		Description: row["description"],
+		Category:    row["category"],
+	}
+	var err error
+	if item.Price, err = parseCSVFloat(row, "price"); err != nil {
+		return item, err
+	}
+	if row["prep_seconds"] != "" {
+		if item.PrepSeconds, err = strconv.Atoi(row["prep_seconds"]); err != nil {
+			return item, fmt.Errorf("prep_seconds is not an integer")
+		}
+	}
+	for _, pair := range strings.Split(row["ingredients"], ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		ingredientID, quantity, found := strings.Cut(pair, ":")
+		if !found {
+			return item, fmt.Errorf("ingredient %q should be written as ingredient_id:quantity", pair)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(quantity), 64)
+		if err != nil {
+			return item, fmt.Errorf("quantity of ingredient %s is not a float", ingredientID)
+		}
+		item.Ingredients = append(item.Ingredients, models.MenuItemIngredient{
+			IngredientID: strings.TrimSpace(ingredientID),
+			Quantity:     value,
+		})
+	}
+	return item, nil
+}
+
+func menuItemToCSV(item models.MenuItem) []string {
+	ingredients := make([]string, 0, len(item.Ingredients))
+	for _, ingredient := range item.Ingredients {
+		ingredients = append(ingredients, ingredient.IngredientID+":"+formatCSVFloat(ingredient.Quantity))
+	}
+	prepSeconds := ""
+	if item.PrepSeconds > 0 {
+		prepSeconds = strconv.Itoa(item.PrepSeconds)
+	}
+	return []string{
+		item.ID,
+		item.Name,
+		item.Description,
+		item.Category,
+		formatCSVFloat(item.Price),
+		prepSeconds,
+		strings.Join(ingredients, ";"),
+	}
+}
+
+// parseInventoryImport decodes the inventory items of an import like parseMenuImport
+func parseInventoryImport(r *http.Request) ([]models.InventoryItem, []models.ImportError, error) {
+	mediaType, err := importMediaType(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var items []models.InventoryItem
+	var rowErrors []models.ImportError
+	if mediaType == "application/json" {
+		rows, err := readJSONRows(r.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, row := range rows {
+			var item models.InventoryItem
+			if err := json.Unmarshal(row, &item); err != nil {
+				rowErrors = append(rowErrors, models.ImportError{Row: i + 1, Error: err.Error()})
+			}
+			items = append(items, item)
+		}
+		return items, rowErrors, nil
+	}
+
+	rows, err := readCSVRows(r.Body, inventoryCSVHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, row := range rows {
+		item := models.InventoryItem{
+			IngredientID: row["ingredient_id"],
+			Name:         row["name"],
+			Unit:         row["unit"],
+		}
+		if item.Quantity, err = parseCSVFloat(row, "quantity"); err != nil {
+			rowErrors = append(rowErrors, models.ImportError{Row: i + 1, ID: item.IngredientID, Error: err.Error()})
+		}
+		items = append(items, item)
+	}
+	return items, rowErrors, nil
+}
+
+func inventoryItemToCSV(item models.InventoryItem) []string {
+	return []string{item.IngredientID, item.Name, formatCSVFloat(item.Quantity), item.Unit}
+}
+
+// writeImportResult answers 422 when any row was rejected and nothing was saved
+func writeImportResult(w http.ResponseWriter, r *http.Request, result models.ImportResult) {
+	if len(result.Errors) > 0 {
+		writeJSON(w, r, http.StatusUnprocessableEntity, result)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, result)
+}
+
+// writeExport sends the rows as a CSV attachment, or the items as JSON when format is json
+func writeExport(w http.ResponseWriter, r *http.Request, name string, items any, header []string, rows [][]string) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json":
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
+		writeJSON(w, r, http.StatusOK, items)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".csv"))
+		w.WriteHeader(http.StatusOK)
+		writer := csv.NewWriter(w)
+		if err := writer.Write(header); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to write export", "error", err)
+			return
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to write export", "error", err)
+		}
+	default:
+		ErrorResponse(w, "unsupported export format (should be json or csv)", http.StatusBadRequest)
+	}
+}
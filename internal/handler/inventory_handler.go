@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"hot-cofee/internal/service"
@@ -14,25 +15,42 @@ import (
 
 var InventoryService = service.NewInventoryService()
 
-func InventoryEndpoints(mux *http.ServeMux) {
+func InventoryEndpoints(mux *Router) {
 	mux.HandleFunc("POST /inventory", PostInventoryHandler)
 	mux.HandleFunc("POST /inventory/", PostInventoryHandler)
 
 	mux.HandleFunc("GET /inventory", GetAllInventoryHandler)
 	mux.HandleFunc("GET /inventory/", GetAllInventoryHandler)
 
+	mux.HandleFunc("POST /inventory/import", PostInventoryImportHandler)
+	mux.HandleFunc("POST /inventory/import/", PostInventoryImportHandler)
+
+	mux.HandleFunc("GET /inventory/export", GetInventoryExportHandler)
+	mux.HandleFunc("GET /inventory/export/", GetInventoryExportHandler)
+
 	mux.HandleFunc("GET /inventory/{id}", GetInventoryByIDHandler)
 	mux.HandleFunc("GET /inventory/{id}/", GetInventoryByIDHandler)
 
 	mux.HandleFunc("PUT /inventory/{id}", PutInventoryHandler)
 	mux.HandleFunc("PUT /inventory/{id}/", PutInventoryHandler)
 
+	mux.HandleFunc("PATCH /inventory/{id}", PatchInventoryHandler)
+	mux.HandleFunc("PATCH /inventory/{id}/", PatchInventoryHandler)
+
 	mux.HandleFunc("DELETE /inventory/{id}", DeleteInventoryByIDHandler)
 	mux.HandleFunc("DELETE /inventory/{id}/", DeleteInventoryByIDHandler)
+
+	mux.HandleFunc("POST /inventory/{id}/restore", RestoreInventoryHandler)
+	mux.HandleFunc("POST /inventory/{id}/restore/", RestoreInventoryHandler)
 }
 
 func GetAllInventoryHandler(w http.ResponseWriter, r *http.Request) {
-	inventory, err := InventoryService.GetAllInventory()
+	includeArchived, err := parseIncludeArchived(r)
+	if err != nil {
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	inventory, err := InventoryService.ListInventory(includeArchived)
 	if err != nil {
 		ErrorResponse(w, "Could not retrieve inventory data", http.StatusInternalServerError)
 		return
@@ -53,21 +71,22 @@ func GetAllInventoryHandler(w http.ResponseWriter, r *http.Request) {
 		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
 	}
 
-	slog.Info("Retrieved all inventory items")
+	slog.InfoContext(r.Context(), "Retrieved all inventory items")
 }
 
 func GetInventoryByIDHandler(w http.ResponseWriter, r *http.Request) {
 	itemId := r.PathValue("id")
 	item, err := InventoryService.GetInventoryByID(itemId)
 	if errors.Is(err, service.ErrInventoryNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+		ServiceError(w, err, http.StatusNotFound)
 		return
 	}
 	// Set response headers
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(item.Version))
 	w.WriteHeader(http.StatusOK)
 
 	// Marshal the items with indentation
@@ -81,21 +100,40 @@ func GetInventoryByIDHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err = w.Write(jsonData); err != nil {
 		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
 	}
-	slog.Info("Retrieved inventory item", "ID", itemId)
+	slog.InfoContext(r.Context(), "Retrieved inventory item", "ID", itemId)
 }
 
 func DeleteInventoryByIDHandler(w http.ResponseWriter, r *http.Request) {
 	itemId := r.PathValue("id")
-	err := InventoryService.DeleteInventoryItem(itemId)
+	current, _ := InventoryService.GetInventoryByID(itemId)
+	err := InventoryService.DeleteInventoryItem(r.Context(), itemId, expectedVersion(r, current.Version), staffIDFrom(r))
 	if errors.Is(err, service.ErrInventoryNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+		ServiceError(w, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+		ServiceError(w, err, http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
-	slog.Info("Deleted inventory item id", "ID", itemId)
+	slog.InfoContext(r.Context(), "Archived inventory item", "ID", itemId)
+}
+
+// RestoreInventoryHandler brings an archived inventory item back
+func RestoreInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	itemId := r.PathValue("id")
+	current, _ := InventoryService.GetInventoryByID(itemId)
+	if err := InventoryService.RestoreInventoryItem(r.Context(), itemId, expectedVersion(r, current.Version), staffIDFrom(r)); err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	item, err := InventoryService.GetInventoryByID(itemId)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(item.Version))
+	writeJSON(w, r, http.StatusOK, item)
+	slog.InfoContext(r.Context(), "Restored inventory item", "ID", itemId)
 }
 
 func parseInventoryItem(r *http.Request) (models.InventoryItem, error) {
@@ -125,7 +163,7 @@ func parseInventoryItem(r *http.Request) (models.InventoryItem, error) {
 			Unit:         r.FormValue("unit"),
 		}
 	} else {
-		return item, fmt.Errorf("unsupported content type")
+		return item, ErrUnsupportedContentType
 	}
 
 	return item, nil
@@ -134,35 +172,40 @@ func parseInventoryItem(r *http.Request) (models.InventoryItem, error) {
 func PostInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	item, err := parseInventoryItem(r)
 	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 
 	// Call service to add new inventory item
-	if err = InventoryService.AddNewInventoryItem(item); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
+	item.UpdatedBy = staffIDFrom(r)
+	if err = InventoryService.AddNewInventoryItem(r.Context(), item); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
-
-	w.WriteHeader(http.StatusCreated)
-	if _, err = w.Write([]byte("Inventory item added successfully")); err != nil {
-		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+	item, err = InventoryService.GetInventoryByID(item.IngredientID)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
 	}
-	slog.Info("Added item", "ID", item.IngredientID)
+	w.Header().Set("ETag", etag(item.Version))
+	writeCreated(w, r, "/inventory/"+url.PathEscape(item.IngredientID), item)
+	slog.InfoContext(r.Context(), "Added item", "ID", item.IngredientID)
 }
 
 func PutInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id") // Use URL query to get id if necessary
 	item, err := parseInventoryItem(r)
 	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 
@@ -172,21 +215,122 @@ func PutInventoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	current, _ := InventoryService.GetInventoryByID(id)
+
 	// Call service to modify inventory item
-	if err = InventoryService.ModifyInventoryItem(item); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
+	item.UpdatedBy = staffIDFrom(r)
+	if err = InventoryService.ModifyInventoryItem(r.Context(), item, expectedVersion(r, current.Version)); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil && !errors.Is(err, service.ErrNothingToModify) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	item, err = InventoryService.GetInventoryByID(id)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
-	} else if errors.Is(err, service.ErrNothingToModify) {
-		ErrorResponse(w, err.Error(), http.StatusNoContent)
+	}
+	w.Header().Set("ETag", etag(item.Version))
+	writeJSON(w, r, http.StatusOK, item)
+	slog.InfoContext(r.Context(), "Modified the inventory item: ", "ID", id)
+}
+
+func PatchInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	current, err := InventoryService.GetInventoryByID(id)
+	if errors.Is(err, service.ErrInventoryNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	var item models.InventoryItem
+	err = patchResource(r, current, &item)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if errors.Is(err, ErrPatchTestFailed) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	if id != item.IngredientID {
+		ErrorResponse(w, "ingredient ID cannot be patched", http.StatusBadRequest)
 		return
 	}
+	item.UpdatedBy = staffIDFrom(r)
 
-	w.WriteHeader(http.StatusOK)
-	if _, err = w.Write([]byte("Inventory item modified successfully")); err != nil {
-		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+	if err = InventoryService.ModifyInventoryItem(r.Context(), item, patchVersion(r, current.Version)); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil && !errors.Is(err, service.ErrNothingToModify) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	item, err = InventoryService.GetInventoryByID(id)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(item.Version))
+	writeJSON(w, r, http.StatusOK, item)
+	slog.InfoContext(r.Context(), "Patched inventory item", "ID", id)
+}
+
+func PostInventoryImportHandler(w http.ResponseWriter, r *http.Request) {
+	mode, dryRun, err := parseImportOptions(r)
+	if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	items, rowErrors, err := parseInventoryImport(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	if len(rowErrors) > 0 {
+		writeImportResult(w, r, models.ImportResult{Mode: mode, DryRun: dryRun, Errors: rowErrors})
+		return
+	}
+
+	for i := range items {
+		items[i].UpdatedBy = staffIDFrom(r)
+	}
+	result, err := InventoryService.ImportInventory(r.Context(), items, mode, dryRun)
+	if errors.Is(err, service.ErrInvalidImportMode) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeImportResult(w, r, result)
+	slog.InfoContext(r.Context(), "Imported inventory items", "mode", mode, "dry_run", dryRun, "created", result.Created,
+		"updated", result.Updated, "deleted", result.Deleted, "errors", len(result.Errors))
+}
+
+func GetInventoryExportHandler(w http.ResponseWriter, r *http.Request) {
+	includeArchived, err := parseIncludeArchived(r)
+	if err != nil {
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	items, err := InventoryService.ListInventory(includeArchived)
+	if err != nil {
+		ErrorResponse(w, "Could not retrieve inventory data", http.StatusInternalServerError)
+		return
+	}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, inventoryItemToCSV(item))
 	}
-	slog.Info("Modified the inventory item: ", "ID", id)
+	writeExport(w, r, "inventory", items, inventoryCSVHeader, rows)
+	slog.InfoContext(r.Context(), "Exported inventory items", "format", r.URL.Query().Get("format"))
 }
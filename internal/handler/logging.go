@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"hot-cofee/internal/service"
+)
+
+// maxRequestIDLength keeps clients from filling the logs through X-Request-ID
+const maxRequestIDLength = 128
+
+// LogRequests wraps the handler so that every request has an ID and is logged and counted in
+// the metrics once it is answered.
+// The ID is taken from the X-Request-ID header if the client sent a usable one and generated
+// otherwise; it is sent back in X-Request-ID and carried in the context of the request, so that
+// the logs of the handlers and the service layer and the audit log name it too.
+func LogRequests(mux *Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		r.Header.Set("X-Request-ID", id)
+		r = r.WithContext(service.WithRequestID(r.Context(), id))
+		w.Header().Set("X-Request-ID", id)
+		_, pattern := mux.Handler(r)
+		route := unversionedRoute(pattern)
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		duration := time.Since(start)
+		observeRequest(r.Method, route, sw.status, duration)
+
+		level := slog.LevelInfo
+		if sw.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if sw.status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", sw.status),
+			slog.Int64("bytes", sw.bytes),
+			slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+			slog.String("remote", r.RemoteAddr),
+		}
+		if sw.problem != nil {
+			attrs = append(attrs, slog.String("error_code", sw.problem.Code), slog.String("error", sw.problem.Detail))
+		}
+		slog.LogAttrs(r.Context(), level, "Handled request", attrs...)
+	})
+}
+
+// statusWriter passes the response through while counting it, and keeps the problem it answered with
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	bytes   int64
+	problem *Problem
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	if sw.status == 0 {
+		sw.status = statusCode
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statusWriter) Write(data []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(data)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps server-sent events working through the writer
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// noteProblem hands the problem a request is answered with to the request log, looking through
+// the writers that wrap the one of LogRequests
+func noteProblem(w http.ResponseWriter, problem Problem) {
+	for {
+		if sw, ok := w.(*statusWriter); ok {
+			sw.problem = &problem
+			return
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces, which are safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(buf)
+}
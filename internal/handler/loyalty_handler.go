@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+var LoyaltyService = service.NewLoyaltyService()
+
+func LoyaltyEndpoints(mux *Router) {
+	mux.HandleFunc("GET /loyalty/program", GetLoyaltyProgramHandler)
+	mux.HandleFunc("GET /loyalty/program/", GetLoyaltyProgramHandler)
+
+	mux.HandleFunc("PUT /loyalty/program", PutLoyaltyProgramHandler)
+	mux.HandleFunc("PUT /loyalty/program/", PutLoyaltyProgramHandler)
+
+	mux.HandleFunc("GET /customers/{id}/loyalty", GetLoyaltyBalanceHandler)
+	mux.HandleFunc("GET /customers/{id}/loyalty/", GetLoyaltyBalanceHandler)
+
+	mux.HandleFunc("GET /customers/{id}/loyalty/transactions", GetLoyaltyTransactionsHandler)
+	mux.HandleFunc("GET /customers/{id}/loyalty/transactions/", GetLoyaltyTransactionsHandler)
+}
+
+func GetLoyaltyProgramHandler(w http.ResponseWriter, r *http.Request) {
+	program, err := LoyaltyService.GetProgram()
+	if err != nil {
+		ErrorResponse(w, "Could not retrieve loyalty program", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, program)
+	slog.InfoContext(r.Context(), "Retrieved loyalty program")
+}
+
+func PutLoyaltyProgramHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		ErrorResponse(w, ErrUnsupportedContentType.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	var program models.LoyaltyProgram
+	if err := json.NewDecoder(r.Body).Decode(&program); err != nil {
+		ErrorResponse(w, "invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if err := LoyaltyService.SetProgram(r.Context(), program); err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, program)
+	slog.InfoContext(r.Context(), "Updated loyalty program")
+}
+
+func GetLoyaltyBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+	balance, err := LoyaltyService.GetBalance(ID)
+	if errors.Is(err, service.ErrLoyaltyNotRead) || errors.Is(err, service.ErrCustomerNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, balance)
+	slog.InfoContext(r.Context(), "Retrieved loyalty balance", "customer", ID)
+}
+
+func GetLoyaltyTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+	transactions, err := LoyaltyService.GetTransactions(ID)
+	if errors.Is(err, service.ErrLoyaltyNotRead) || errors.Is(err, service.ErrCustomerNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, transactions)
+	slog.InfoContext(r.Context(), "Retrieved loyalty transactions", "customer", ID)
+}
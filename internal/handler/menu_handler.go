@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"hot-cofee/internal/service"
@@ -14,25 +15,42 @@ import (
 
 var MenuService = service.NewMenuService()
 
-func MenuEndpoints(mux *http.ServeMux) {
+func MenuEndpoints(mux *Router) {
 	mux.HandleFunc("POST /menu", PostMenuHandler)
 	mux.HandleFunc("POST /menu/", PostMenuHandler)
 
 	mux.HandleFunc("GET /menu", GetAllMenuHandler)
 	mux.HandleFunc("GET /menu/", GetAllMenuHandler)
 
+	mux.HandleFunc("POST /menu/import", PostMenuImportHandler)
+	mux.HandleFunc("POST /menu/import/", PostMenuImportHandler)
+
+	mux.HandleFunc("GET /menu/export", GetMenuExportHandler)
+	mux.HandleFunc("GET /menu/export/", GetMenuExportHandler)
+
 	mux.HandleFunc("GET /menu/{id}", GetMenuByIDHandler)
 	mux.HandleFunc("GET /menu/{id}/", GetMenuByIDHandler)
 
 	mux.HandleFunc("PUT /menu/{id}", PutMenuHandler)
 	mux.HandleFunc("PUT /menu/{id}/", PutMenuHandler)
 
+	mux.HandleFunc("PATCH /menu/{id}", PatchMenuHandler)
+	mux.HandleFunc("PATCH /menu/{id}/", PatchMenuHandler)
+
 	mux.HandleFunc("DELETE /menu/{id}", DeleteMenuByIDHandler)
 	mux.HandleFunc("DELETE /menu/{id}/", DeleteMenuByIDHandler)
+
+	mux.HandleFunc("POST /menu/{id}/restore", RestoreMenuHandler)
+	mux.HandleFunc("POST /menu/{id}/restore/", RestoreMenuHandler)
 }
 
 func GetAllMenuHandler(w http.ResponseWriter, r *http.Request) {
-	menu, err := MenuService.GetAllMenu()
+	includeArchived, err := parseIncludeArchived(r)
+	if err != nil {
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	menu, err := MenuService.ListMenu(includeArchived)
 	if err != nil {
 		ErrorResponse(w, "Could not retrieve menu data", http.StatusInternalServerError)
 		return
@@ -51,21 +69,22 @@ func GetAllMenuHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err = w.Write(jsonData); err != nil {
 		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
 	}
-	slog.Info("Retrieved all menu products")
+	slog.InfoContext(r.Context(), "Retrieved all menu products")
 }
 
 func GetMenuByIDHandler(w http.ResponseWriter, r *http.Request) {
 	itemId := r.PathValue("id")
 	item, err := MenuService.GetMenuByID(itemId)
 	if errors.Is(err, service.ErrMenuNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+		ServiceError(w, err, http.StatusNotFound)
 		return
 	}
 	// Set response headers
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(item.Version))
 	w.WriteHeader(http.StatusOK)
 
 	// Marshal the items with indentation
@@ -79,21 +98,40 @@ func GetMenuByIDHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err = w.Write(jsonData); err != nil {
 		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
 	}
-	slog.Info("Retrieved menu item", "ID", item.ID)
+	slog.InfoContext(r.Context(), "Retrieved menu item", "ID", item.ID)
 }
 
 func DeleteMenuByIDHandler(w http.ResponseWriter, r *http.Request) {
 	itemId := r.PathValue("id")
-	err := MenuService.DeleteMenuItem(itemId)
+	current, _ := MenuService.GetMenuByID(itemId)
+	err := MenuService.DeleteMenuItem(r.Context(), itemId, expectedVersion(r, current.Version))
 	if errors.Is(err, service.ErrMenuNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+		ServiceError(w, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+		ServiceError(w, err, http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
-	slog.Info("Deleted menu item", "ID", itemId)
+	slog.InfoContext(r.Context(), "Archived menu item", "ID", itemId)
+}
+
+// RestoreMenuHandler brings an archived menu item back
+func RestoreMenuHandler(w http.ResponseWriter, r *http.Request) {
+	itemId := r.PathValue("id")
+	current, _ := MenuService.GetMenuByID(itemId)
+	if err := MenuService.RestoreMenuItem(r.Context(), itemId, expectedVersion(r, current.Version)); err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	item, err := MenuService.GetMenuByID(itemId)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(item.Version))
+	writeJSON(w, r, http.StatusOK, item)
+	slog.InfoContext(r.Context(), "Restored menu item", "ID", itemId)
 }
 
 func parseMenuItem(r *http.Request) (models.MenuItem, error) {
@@ -114,6 +152,12 @@ func parseMenuItem(r *http.Request) (models.MenuItem, error) {
 		if err != nil {
 			return item, fmt.Errorf("price is not a float")
 		}
+		prepSeconds := 0
+		if value := r.FormValue("prep_seconds"); value != "" {
+			if prepSeconds, err = strconv.Atoi(value); err != nil {
+				return item, fmt.Errorf("prep_seconds is not an integer")
+			}
+		}
 
 		// Parse ingredients from JSON format within form data
 		var ingredients []models.MenuItemIngredient
@@ -127,7 +171,9 @@ func parseMenuItem(r *http.Request) (models.MenuItem, error) {
 			ID:          r.FormValue("product_id"),
 			Name:        r.FormValue("name"),
 			Description: r.FormValue("description"),
+			Category:    r.FormValue("category"),
 			Price:       price,
+			PrepSeconds: prepSeconds,
 			Ingredients: ingredients,
 		}
 	} else {
@@ -140,33 +186,37 @@ func parseMenuItem(r *http.Request) (models.MenuItem, error) {
 func PostMenuHandler(w http.ResponseWriter, r *http.Request) {
 	item, err := parseMenuItem(r)
 	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	if err := MenuService.AddNewMenuItem(item); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
+	if err := MenuService.AddNewMenuItem(r.Context(), item); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
-
-	w.WriteHeader(http.StatusCreated)
-	if _, err = w.Write([]byte("Menu item added successfully")); err != nil {
-		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+	item, err = MenuService.GetMenuByID(item.ID)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
 	}
-	slog.Info("Created menu item", "ID", item.ID)
+	w.Header().Set("ETag", etag(item.Version))
+	writeCreated(w, r, "/menu/"+url.PathEscape(item.ID), item)
+	slog.InfoContext(r.Context(), "Created menu item", "ID", item.ID)
 }
 
 func PutMenuHandler(w http.ResponseWriter, r *http.Request) {
 	item, err := parseMenuItem(r)
 	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 
@@ -176,18 +226,116 @@ func PutMenuHandler(w http.ResponseWriter, r *http.Request) {
 		ErrorResponse(w, "product ID does not match id", http.StatusBadRequest)
 		return
 	}
+	current, _ := MenuService.GetMenuByID(id)
+
+	if err := MenuService.ModifyMenuItem(r.Context(), item, expectedVersion(r, current.Version)); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil && !errors.Is(err, service.ErrNothingToModify) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	item, err = MenuService.GetMenuByID(id)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(item.Version))
+	writeJSON(w, r, http.StatusOK, item)
+	slog.InfoContext(r.Context(), "Updated menu item", "ID", item.ID)
+}
 
-	if err := MenuService.ModifyMenuItem(item); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
+func PatchMenuHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	current, err := MenuService.GetMenuByID(id)
+	if errors.Is(err, service.ErrMenuNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	var item models.MenuItem
+	err = patchResource(r, current, &item)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if errors.Is(err, ErrPatchTestFailed) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	if id != item.ID {
+		ErrorResponse(w, "product ID cannot be patched", http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	if _, err = w.Write([]byte("Menu item updated successfully")); err != nil {
-		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+	if err := MenuService.ModifyMenuItem(r.Context(), item, patchVersion(r, current.Version)); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil && !errors.Is(err, service.ErrNothingToModify) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	item, err = MenuService.GetMenuByID(id)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(item.Version))
+	writeJSON(w, r, http.StatusOK, item)
+	slog.InfoContext(r.Context(), "Patched menu item", "ID", id)
+}
+
+func PostMenuImportHandler(w http.ResponseWriter, r *http.Request) {
+	mode, dryRun, err := parseImportOptions(r)
+	if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	items, rowErrors, err := parseMenuImport(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	if len(rowErrors) > 0 {
+		writeImportResult(w, r, models.ImportResult{Mode: mode, DryRun: dryRun, Errors: rowErrors})
+		return
+	}
+
+	result, err := MenuService.ImportMenu(r.Context(), items, mode, dryRun)
+	if errors.Is(err, service.ErrInvalidImportMode) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeImportResult(w, r, result)
+	slog.InfoContext(r.Context(), "Imported menu items", "mode", mode, "dry_run", dryRun, "created", result.Created,
+		"updated", result.Updated, "deleted", result.Deleted, "errors", len(result.Errors))
+}
+
+func GetMenuExportHandler(w http.ResponseWriter, r *http.Request) {
+	includeArchived, err := parseIncludeArchived(r)
+	if err != nil {
+		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	items, err := MenuService.ListMenu(includeArchived)
+	if err != nil {
+		ErrorResponse(w, "Could not retrieve menu data", http.StatusInternalServerError)
+		return
+	}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, menuItemToCSV(item))
 	}
-	slog.Info("Updated menu item", "ID", item.ID)
+	writeExport(w, r, "menu", items, menuCSVHeader, rows)
+	slog.InfoContext(r.Context(), "Exported menu items", "format", r.URL.Query().Get("format"))
 }
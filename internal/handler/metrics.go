@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"hot-cofee/internal/service"
+)
+
+// requestBuckets are the upper bounds in seconds of the request duration histogram
+var requestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+type latencyKey struct {
+	method string
+	route  string
+}
+
+// latency is a histogram of request durations, counts[i] counting those up to requestBuckets[i]
+type latency struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// httpMetrics counts the answered requests since the server started
+var httpMetrics = struct {
+	sync.Mutex
+	requests  map[requestKey]uint64
+	latencies map[latencyKey]*latency
+}{
+	requests:  make(map[requestKey]uint64),
+	latencies: make(map[latencyKey]*latency),
+}
+
+func MetricsEndpoints(mux *Router) {
+	mux.HandleFunc("GET /metrics", GetMetricsHandler)
+	mux.HandleFunc("GET /metrics/", GetMetricsHandler)
+}
+
+// observeRequest counts an answered request by its method, route and status. Requests that
+// matched no route are counted as route "unmatched" so that scanners cannot add label values.
+func observeRequest(method, route string, status int, duration time.Duration) {
+	route = metricsRoute(method, route)
+	httpMetrics.Lock()
+	defer httpMetrics.Unlock()
+	httpMetrics.requests[requestKey{method, route, status}]++
+	key := latencyKey{method, route}
+	histogram, ok := httpMetrics.latencies[key]
+	if !ok {
+		histogram = &latency{counts: make([]uint64, len(requestBuckets))}
+		httpMetrics.latencies[key] = histogram
+	}
+	seconds := duration.Seconds()
+	for i, bound := range requestBuckets {
+		if seconds <= bound {
+			histogram.counts[i]++
+		}
+	}
+	histogram.count++
+	histogram.sum += seconds
+}
+
+// metricsRoute turns a route such as "GET /orders/{id}" into its path "/orders/{id}"
+func metricsRoute(method, route string) string {
+	routeMethod, path, found := strings.Cut(route, " ")
+	if !found || routeMethod != method {
+		return "unmatched"
+	}
+	return path
+}
+
+// GetMetricsHandler answers with the metrics in the Prometheus text format
+func GetMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	inventory, err := InventoryService.ListInventory(false)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	writeHTTPMetrics(&buf)
+
+	orders := service.GetOrderMetrics()
+	writeMetricHeader(&buf, "hot_coffee_orders_created_total", "counter", "Orders created since the server started.")
+	writeSample(&buf, "hot_coffee_orders_created_total", nil, float64(orders.Created))
+	writeMetricHeader(&buf, "hot_coffee_orders_closed_total", "counter", "Orders closed since the server started.")
+	writeSample(&buf, "hot_coffee_orders_closed_total", nil, float64(orders.Closed))
+	writeMetricHeader(&buf, "hot_coffee_revenue_total", "counter", "Totals of the orders closed since the server started, before refunds.")
+	writeSample(&buf, "hot_coffee_revenue_total", nil, orders.Revenue)
+	writeMetricHeader(&buf, "hot_coffee_order_close_failures_total", "counter", "Closes of orders that failed, by error code.")
+	reasons := make([]string, 0, len(orders.CloseFailures))
+	for reason := range orders.CloseFailures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		writeSample(&buf, "hot_coffee_order_close_failures_total", []string{"reason", reason}, float64(orders.CloseFailures[reason]))
+	}
+
+	writeMetricHeader(&buf, "hot_coffee_inventory_quantity", "gauge", "Quantity in stock of every inventory item that is not archived.")
+	sort.Slice(inventory, func(i, j int) bool { return inventory[i].IngredientID < inventory[j].IngredientID })
+	for _, item := range inventory {
+		writeSample(&buf, "hot_coffee_inventory_quantity", []string{"ingredient_id", item.IngredientID, "unit", item.Unit}, item.Quantity)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write response", "error", err)
+	}
+}
+
+func writeHTTPMetrics(buf *bytes.Buffer) {
+	httpMetrics.Lock()
+	defer httpMetrics.Unlock()
+
+	writeMetricHeader(buf, "hot_coffee_http_requests_total", "counter", "HTTP requests answered, by method, route and status.")
+	requestKeys := make([]requestKey, 0, len(httpMetrics.requests))
+	for key := range httpMetrics.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		} else if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	for _, key := range requestKeys {
+		labels := []string{"method", key.method, "route", key.route, "status", strconv.Itoa(key.status)}
+		writeSample(buf, "hot_coffee_http_requests_total", labels, float64(httpMetrics.requests[key]))
+	}
+
+	writeMetricHeader(buf, "hot_coffee_http_request_duration_seconds", "histogram", "Time taken to answer HTTP requests, by method and route.")
+	latencyKeys := make([]latencyKey, 0, len(httpMetrics.latencies))
+	for key := range httpMetrics.latencies {
+		latencyKeys = append(latencyKeys, key)
+	}
+	sort.Slice(latencyKeys, func(i, j int) bool {
+		a, b := latencyKeys[i], latencyKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		return a.method < b.method
+	})
+	for _, key := range latencyKeys {
+		histogram := httpMetrics.latencies[key]
+		labels := []string{"method", key.method, "route", key.route}
+		for i, bound := range requestBuckets {
+			writeSample(buf, "hot_coffee_http_request_duration_seconds_bucket", append(labels, "le", formatMetricValue(bound)), float64(histogram.counts[i]))
+		}
+		writeSample(buf, "hot_coffee_http_request_duration_seconds_bucket", append(labels, "le", "+Inf"), float64(histogram.count))
+		writeSample(buf, "hot_coffee_http_request_duration_seconds_sum", labels, histogram.sum)
+		writeSample(buf, "hot_coffee_http_request_duration_seconds_count", labels, float64(histogram.count))
+	}
+}
+
+func writeMetricHeader(buf *bytes.Buffer, name, metricType, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// writeSample writes one line of a metric, labels being given as name, value, name, value...
+func writeSample(buf *bytes.Buffer, name string, labels []string, value float64) {
+	buf.WriteString(name)
+	if len(labels) > 0 {
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, "%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatMetricValue(value))
+	buf.WriteByte('\n')
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
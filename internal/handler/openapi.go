@@ -0,0 +1,543 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+const (
+	contentTypeJSON  = "application/json"
+	contentTypeForm  = "application/x-www-form-urlencoded"
+	contentTypeCSV   = "text/csv"
+	openAPIVersion   = "3.0.3"
+	apiVersion       = "1.0.0"
+	schemaRefPrefix  = "#/components/schemas/"
+	problemSchemaRef = schemaRefPrefix + "Problem"
+)
+
+// apiParameter is a query parameter of an operation
+type apiParameter struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// apiOperation documents one registered route. Request and Response are model values whose
+// schema is generated from their JSON tags, or ready-made schemas as map[string]any.
+type apiOperation struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	// Description adds to the summary, e.g. the meaning of labels
+	Description  string
+	Query        []apiParameter
+	Request      any
+	RequestTypes []string
+	Status       int
+	Response     any
+	ResponseType string
+}
+
+var (
+	statusRequestSchema = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"status": map[string]any{"type": "string", "enum": []string{service.ItemQueued, service.ItemInProgress, service.ItemDone}},
+		},
+	}
+	jsonPatchSchema = map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"op":    map[string]any{"type": "string", "enum": []string{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  map[string]any{"type": "string"},
+				"from":  map[string]any{"type": "string"},
+				"value": map[string]any{},
+			},
+		},
+	}
+	importParameters = []apiParameter{
+		{"mode", "string", "upsert (default) or replace"},
+		{"dry_run", "boolean", "only report what would change"},
+	}
+	exportParameters = []apiParameter{
+		{"format", "string", "json (default) or csv"},
+		{"include_archived", "boolean", "also list the archived items"},
+	}
+	listParameters = []apiParameter{{"include_archived", "boolean", "also list the archived items"}}
+	patchTypes     = []string{contentTypeMergePatch, contentTypeJSONPatch}
+	importTypes    = []string{contentTypeJSON, contentTypeCSV}
+	bodyTypes      = []string{contentTypeJSON, contentTypeForm}
+)
+
+// apiOperations lists every route of the API. CheckOpenAPI fails the start of the server
+// when a route is registered without being listed here or the other way round.
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/openapi.json", Tag: "meta", Summary: "Retrieve this OpenAPI document",
+		Status: http.StatusOK, Response: map[string]any{"type": "object"}},
+
+	{Method: "POST", Path: "/orders", Tag: "orders", Summary: "Create an order and return it with its estimated ready time",
+		Request: models.Order{}, RequestTypes: bodyTypes, Status: http.StatusCreated, Response: models.Order{}},
+	{Method: "GET", Path: "/orders", Tag: "orders", Summary: "Retrieve the orders matching the filter",
+		Query: []apiParameter{
+			{"status", "string", "Open, Ready or Closed"},
+			{"customer", "string", "part of the customer name"},
+			{"customer_id", "integer", "ID of the customer"},
+			{"from", "string", "YYYY-MM-DD or YYYY-MM-DD HH:MM:SS"},
+			{"to", "string", "YYYY-MM-DD or YYYY-MM-DD HH:MM:SS"},
+			{"sort", "string", "id, created_at or customer, prefixed with - for descending"},
+			{"limit", "integer", "maximum number of orders"},
+			{"offset", "integer", "number of orders to skip"},
+		},
+		Status: http.StatusOK, Response: []models.Order{}},
+	{Method: "GET", Path: "/orders/{id}", Tag: "orders", Summary: "Retrieve an order",
+		Status: http.StatusOK, Response: models.Order{}},
+	{Method: "PUT", Path: "/orders/{id}", Tag: "orders", Summary: "Update an order",
+		Request: models.Order{}, RequestTypes: bodyTypes, Status: http.StatusOK, Response: models.Order{}},
+	{Method: "PATCH", Path: "/orders/{id}", Tag: "orders", Summary: "Change part of an order",
+		Request: models.Order{}, RequestTypes: patchTypes, Status: http.StatusOK, Response: models.Order{}},
+	{Method: "DELETE", Path: "/orders/{id}", Tag: "orders", Summary: "Delete an order without payments",
+		Status: http.StatusNoContent},
+	{Method: "POST", Path: "/orders/{id}/close", Tag: "orders", Summary: "Close a fully paid order",
+		Status: http.StatusOK, Response: models.Order{}},
+	{Method: "POST", Path: "/orders/{id}/payments", Tag: "orders", Summary: "Record a payment of an order",
+		Request: models.Payment{}, RequestTypes: bodyTypes, Status: http.StatusCreated, Response: models.Payment{}},
+	{Method: "GET", Path: "/orders/{id}/payments", Tag: "orders", Summary: "Retrieve the payments of an order",
+		Status: http.StatusOK, Response: []models.Payment{}},
+	{Method: "GET", Path: "/orders/{id}/payments/{payment_id}", Tag: "orders", Summary: "Retrieve a payment of an order",
+		Status: http.StatusOK, Response: models.Payment{}},
+	{Method: "POST", Path: "/orders/{id}/refunds", Tag: "orders", Summary: "Refund lines of a closed order",
+		Request: models.Refund{}, RequestTypes: bodyTypes, Status: http.StatusCreated, Response: models.Refund{}},
+	{Method: "GET", Path: "/orders/{id}/refunds", Tag: "orders", Summary: "Retrieve the refunds of an order",
+		Status: http.StatusOK, Response: []models.Refund{}},
+	{Method: "GET", Path: "/orders/{id}/refunds/{refund_id}", Tag: "orders", Summary: "Retrieve a refund of an order",
+		Status: http.StatusOK, Response: models.Refund{}},
+	{Method: "GET", Path: "/orders/{id}/receipt", Tag: "orders", Summary: "Print the receipt of an order",
+		Query:  []apiParameter{{"format", "string", "txt (default), html or escpos"}},
+		Status: http.StatusOK, Response: map[string]any{"type": "string"}, ResponseType: "text/plain"},
+	{Method: "PUT", Path: "/orders/{id}/items/{product_id}/status", Tag: "orders", Summary: "Set the preparation status of a line",
+		Request: statusRequestSchema, RequestTypes: bodyTypes, Status: http.StatusOK, Response: models.Order{}},
+	{Method: "POST", Path: "/orders/{id}/items/{product_id}/advance", Tag: "orders", Summary: "Move a line to its next preparation status",
+		Status: http.StatusOK, Response: models.Order{}},
+
+	{Method: "POST", Path: "/menu", Tag: "menu", Summary: "Add a menu item",
+		Request: models.MenuItem{}, RequestTypes: bodyTypes, Status: http.StatusCreated, Response: models.MenuItem{}},
+	{Method: "GET", Path: "/menu", Tag: "menu", Summary: "Retrieve the menu items that are not archived",
+		Query: listParameters, Status: http.StatusOK, Response: []models.MenuItem{}},
+	{Method: "POST", Path: "/menu/import", Tag: "menu", Summary: "Import menu items from a JSON array or CSV",
+		Query: importParameters, Request: []models.MenuItem{}, RequestTypes: importTypes,
+		Status: http.StatusOK, Response: models.ImportResult{}},
+	{Method: "GET", Path: "/menu/export", Tag: "menu", Summary: "Export the menu items",
+		Query: exportParameters, Status: http.StatusOK, Response: []models.MenuItem{}},
+	{Method: "GET", Path: "/menu/{id}", Tag: "menu", Summary: "Retrieve a menu item",
+		Status: http.StatusOK, Response: models.MenuItem{}},
+	{Method: "PUT", Path: "/menu/{id}", Tag: "menu", Summary: "Update a menu item",
+		Request: models.MenuItem{}, RequestTypes: bodyTypes, Status: http.StatusOK, Response: models.MenuItem{}},
+	{Method: "PATCH", Path: "/menu/{id}", Tag: "menu", Summary: "Change part of a menu item",
+		Request: models.MenuItem{}, RequestTypes: patchTypes, Status: http.StatusOK, Response: models.MenuItem{}},
+	{Method: "DELETE", Path: "/menu/{id}", Tag: "menu", Summary: "Archive a menu item",
+		Status: http.StatusNoContent},
+	{Method: "POST", Path: "/menu/{id}/restore", Tag: "menu", Summary: "Restore an archived menu item",
+		Status: http.StatusOK, Response: models.MenuItem{}},
+
+	{Method: "POST", Path: "/inventory", Tag: "inventory", Summary: "Add an inventory item",
+		Request: models.InventoryItem{}, RequestTypes: bodyTypes, Status: http.StatusCreated, Response: models.InventoryItem{}},
+	{Method: "GET", Path: "/inventory", Tag: "inventory", Summary: "Retrieve the inventory items that are not archived",
+		Query: listParameters, Status: http.StatusOK, Response: []models.InventoryItem{}},
+	{Method: "POST", Path: "/inventory/import", Tag: "inventory", Summary: "Import inventory items from a JSON array or CSV",
+		Query: importParameters, Request: []models.InventoryItem{}, RequestTypes: importTypes,
+		Status: http.StatusOK, Response: models.ImportResult{}},
+	{Method: "GET", Path: "/inventory/export", Tag: "inventory", Summary: "Export the inventory items",
+		Query: exportParameters, Status: http.StatusOK, Response: []models.InventoryItem{}},
+	{Method: "GET", Path: "/inventory/{id}", Tag: "inventory", Summary: "Retrieve an inventory item",
+		Status: http.StatusOK, Response: models.InventoryItem{}},
+	{Method: "PUT", Path: "/inventory/{id}", Tag: "inventory", Summary: "Update an inventory item",
+		Request: models.InventoryItem{}, RequestTypes: bodyTypes, Status: http.StatusOK, Response: models.InventoryItem{}},
+	{Method: "PATCH", Path: "/inventory/{id}", Tag: "inventory", Summary: "Change part of an inventory item",
+		Request: models.InventoryItem{}, RequestTypes: patchTypes, Status: http.StatusOK, Response: models.InventoryItem{}},
+	{Method: "DELETE", Path: "/inventory/{id}", Tag: "inventory", Summary: "Archive an inventory item",
+		Status: http.StatusNoContent},
+	{Method: "POST", Path: "/inventory/{id}/restore", Tag: "inventory", Summary: "Restore an archived inventory item",
+		Status: http.StatusOK, Response: models.InventoryItem{}},
+
+	{Method: "GET", Path: "/queue", Tag: "queue", Summary: "Retrieve the open and ready orders in the order they were placed",
+		Status: http.StatusOK, Response: []models.QueueEntry{}},
+	{Method: "GET", Path: "/queue/events", Tag: "queue", Summary: "Stream order events as Server-Sent Events",
+		Status: http.StatusOK, Response: models.OrderEvent{}, ResponseType: "text/event-stream"},
+
+	{Method: "POST", Path: "/customers", Tag: "customers", Summary: "Add a customer",
+		Request: models.Customer{}, RequestTypes: bodyTypes, Status: http.StatusCreated, Response: models.Customer{}},
+	{Method: "GET", Path: "/customers", Tag: "customers", Summary: "Retrieve all customers",
+		Status: http.StatusOK, Response: []models.Customer{}},
+	{Method: "GET", Path: "/customers/{id}", Tag: "customers", Summary: "Retrieve a customer",
+		Status: http.StatusOK, Response: models.Customer{}},
+	{Method: "PUT", Path: "/customers/{id}", Tag: "customers", Summary: "Update a customer profile",
+		Request: models.Customer{}, RequestTypes: bodyTypes, Status: http.StatusOK, Response: models.Customer{}},
+	{Method: "DELETE", Path: "/customers/{id}", Tag: "customers", Summary: "Delete a customer without orders",
+		Status: http.StatusNoContent},
+	{Method: "GET", Path: "/customers/{id}/orders", Tag: "customers", Summary: "Retrieve the order history of a customer",
+		Status: http.StatusOK, Response: []models.Order{}},
+
+	{Method: "GET", Path: "/loyalty/program", Tag: "loyalty", Summary: "Retrieve the earn rules and rewards",
+		Status: http.StatusOK, Response: models.LoyaltyProgram{}},
+	{Method: "PUT", Path: "/loyalty/program", Tag: "loyalty", Summary: "Replace the earn rules and rewards",
+		Request: models.LoyaltyProgram{}, RequestTypes: []string{contentTypeJSON}, Status: http.StatusOK, Response: models.LoyaltyProgram{}},
+	{Method: "GET", Path: "/customers/{id}/loyalty", Tag: "loyalty", Summary: "Retrieve the loyalty balance of a customer",
+		Status: http.StatusOK, Response: models.LoyaltyBalance{}},
+	{Method: "GET", Path: "/customers/{id}/loyalty/transactions", Tag: "loyalty", Summary: "Retrieve the loyalty history of a customer",
+		Status: http.StatusOK, Response: []models.LoyaltyTransaction{}},
+
+	{Method: "POST", Path: "/promotions", Tag: "promotions", Summary: "Add a promotion",
+		Request: models.Promotion{}, RequestTypes: []string{contentTypeJSON}, Status: http.StatusCreated, Response: models.Promotion{}},
+	{Method: "GET", Path: "/promotions", Tag: "promotions", Summary: "Retrieve all promotions",
+		Status: http.StatusOK, Response: []models.Promotion{}},
+	{Method: "GET", Path: "/promotions/{id}", Tag: "promotions", Summary: "Retrieve a promotion",
+		Status: http.StatusOK, Response: models.Promotion{}},
+	{Method: "PUT", Path: "/promotions/{id}", Tag: "promotions", Summary: "Update a promotion",
+		Request: models.Promotion{}, RequestTypes: []string{contentTypeJSON}, Status: http.StatusOK, Response: models.Promotion{}},
+	{Method: "DELETE", Path: "/promotions/{id}", Tag: "promotions", Summary: "Delete a promotion",
+		Status: http.StatusNoContent},
+
+	{Method: "POST", Path: "/taxes", Tag: "taxes", Summary: "Add a tax rate",
+		Request: models.TaxRate{}, RequestTypes: []string{contentTypeJSON}, Status: http.StatusCreated, Response: models.TaxRate{}},
+	{Method: "GET", Path: "/taxes", Tag: "taxes", Summary: "Retrieve all tax rates",
+		Status: http.StatusOK, Response: []models.TaxRate{}},
+	{Method: "GET", Path: "/taxes/{id}", Tag: "taxes", Summary: "Retrieve a tax rate",
+		Status: http.StatusOK, Response: models.TaxRate{}},
+	{Method: "PUT", Path: "/taxes/{id}", Tag: "taxes", Summary: "Update a tax rate",
+		Request: models.TaxRate{}, RequestTypes: []string{contentTypeJSON}, Status: http.StatusOK, Response: models.TaxRate{}},
+	{Method: "DELETE", Path: "/taxes/{id}", Tag: "taxes", Summary: "Delete a tax rate",
+		Status: http.StatusNoContent},
+
+	{Method: "POST", Path: "/staff", Tag: "staff", Summary: "Add a member of staff",
+		Request: models.Staff{}, RequestTypes: []string{contentTypeJSON}, Status: http.StatusCreated, Response: models.Staff{}},
+	{Method: "GET", Path: "/staff", Tag: "staff", Summary: "Retrieve all staff",
+		Status: http.StatusOK, Response: []models.Staff{}},
+	{Method: "GET", Path: "/staff/{id}", Tag: "staff", Summary: "Retrieve a member of staff",
+		Status: http.StatusOK, Response: models.Staff{}},
+	{Method: "PUT", Path: "/staff/{id}", Tag: "staff", Summary: "Update a member of staff",
+		Request: models.Staff{}, RequestTypes: []string{contentTypeJSON}, Status: http.StatusOK, Response: models.Staff{}},
+	{Method: "DELETE", Path: "/staff/{id}", Tag: "staff", Summary: "Delete a member of staff without shifts",
+		Status: http.StatusNoContent},
+	{Method: "POST", Path: "/staff/{id}/clock-in", Tag: "staff", Summary: "Clock in and open a shift",
+		Status: http.StatusCreated, Response: models.Shift{}},
+	{Method: "POST", Path: "/staff/{id}/clock-out", Tag: "staff", Summary: "Clock out and close the open shift",
+		Status: http.StatusOK, Response: models.Shift{}},
+	{Method: "GET", Path: "/staff/{id}/shifts", Tag: "staff", Summary: "Retrieve the shifts of a member of staff",
+		Status: http.StatusOK, Response: []models.Shift{}},
+	{Method: "GET", Path: "/shifts", Tag: "staff", Summary: "Retrieve all shifts",
+		Status: http.StatusOK, Response: []models.Shift{}},
+	{Method: "GET", Path: "/shifts/{id}", Tag: "staff", Summary: "Retrieve a shift",
+		Status: http.StatusOK, Response: models.Shift{}},
+
+	{Method: "POST", Path: "/api-keys", Tag: "api-keys", Summary: "Issue an API key, returned only in this response",
+		Request: models.APIKey{}, RequestTypes: []string{contentTypeJSON}, Status: http.StatusCreated, Response: models.IssuedAPIKey{}},
+	{Method: "GET", Path: "/api-keys", Tag: "api-keys", Summary: "Retrieve the issued API keys",
+		Status: http.StatusOK, Response: []models.APIKey{}},
+	{Method: "GET", Path: "/api-keys/{id}", Tag: "api-keys", Summary: "Retrieve an issued API key",
+		Status: http.StatusOK, Response: models.APIKey{}},
+	{Method: "DELETE", Path: "/api-keys/{id}", Tag: "api-keys", Summary: "Revoke an API key",
+		Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/audit", Tag: "audit", Summary: "Retrieve the audit log, newest first",
+		Query: []apiParameter{
+			{"entity", "string", "order, menu_item, inventory_item, customer, loyalty_program, promotion, tax, staff or api_key"},
+			{"entity_id", "string", "ID of the changed resource"},
+			{"action", "string", "e.g. create, update, delete, close, pay, refund, import"},
+			{"key_id", "string", "ID of the API key the change was made with"},
+			{"staff_id", "integer", "ID of the member of staff who made the change"},
+			{"request_id", "string", "X-Request-ID of the request that made the change"},
+			{"from", "string", "YYYY-MM-DD or YYYY-MM-DD HH:MM:SS"},
+			{"to", "string", "YYYY-MM-DD or YYYY-MM-DD HH:MM:SS"},
+			{"limit", "integer", "maximum number of entries"},
+			{"offset", "integer", "number of entries to skip"},
+		},
+		Status: http.StatusOK, Response: []models.AuditEntry{}},
+
+	{Method: "GET", Path: "/metrics", Tag: "metrics", Summary: "Get the request, order and inventory metrics in the Prometheus text format",
+		Description: "The reason label of hot_coffee_order_close_failures_total is the error code of the failed close, " +
+			"e.g. insufficient_stock, order_not_paid, order_closed or not_found, and other for errors without a code.",
+		Status: http.StatusOK, Response: map[string]any{"type": "string"}, ResponseType: "text/plain"},
+
+	{Method: "GET", Path: "/reports/total-sales", Tag: "reports", Summary: "Get the gross, discount, refund, tax and net sales",
+		Status: http.StatusOK, Response: models.TotalSales{}},
+	{Method: "GET", Path: "/reports/popular-items", Tag: "reports", Summary: "Get the most sold menu items",
+		Status: http.StatusOK, Response: []models.PopularItem{}},
+	{Method: "GET", Path: "/reports/customer-lifetime-value", Tag: "reports", Summary: "Get the closed orders and total spent per customer",
+		Status: http.StatusOK, Response: []models.CustomerLifetimeValue{}},
+	{Method: "GET", Path: "/reports/payments-by-method", Tag: "reports", Summary: "Get payment counts, amounts, tips and change per method",
+		Status: http.StatusOK, Response: []models.PaymentMethodSummary{}},
+	{Method: "GET", Path: "/reports/sales-by-staff", Tag: "reports", Summary: "Get the orders taken and closed and the sales and tips per member of staff",
+		Status: http.StatusOK, Response: []models.StaffSales{}},
+	{Method: "GET", Path: "/reports/sales-by-shift", Tag: "reports", Summary: "Get the orders closed and the sales and tips per shift",
+		Status: http.StatusOK, Response: []models.ShiftSales{}},
+}
+
+func OpenAPIEndpoints(mux *Router) {
+	mux.HandleFunc("GET /openapi.json", GetOpenAPIHandler)
+}
+
+func GetOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	// The document describes the version it is read from, e.g. /v1/openapi.json describes /v1
+	server := strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/openapi.json")
+	if server == "" {
+		server = "/"
+	}
+	writeJSON(w, r, http.StatusOK, openAPIDocument(server))
+	slog.InfoContext(r.Context(), "Retrieved OpenAPI document")
+}
+
+// CheckOpenAPI reports the registered routes missing from apiOperations and the documented
+// operations that are not registered, in every API version
+func CheckOpenAPI(mux *Router) error {
+	documented := make(map[string]bool)
+	var expected []string
+	for _, version := range APIVersions {
+		for _, operation := range apiOperations {
+			route := operation.Method + " " + version.Prefix + operation.Path
+			documented[route] = true
+			expected = append(expected, route)
+		}
+	}
+	var errs []error
+	for _, route := range mux.Routes() {
+		if !documented[route] {
+			errs = append(errs, fmt.Errorf("route %s is not described in the OpenAPI document", route))
+		}
+		delete(documented, route)
+	}
+	for _, route := range expected {
+		if documented[route] {
+			errs = append(errs, fmt.Errorf("OpenAPI operation %s is not registered", route))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// openAPIDocument builds the OpenAPI 3 document from apiOperations and the models
+func openAPIDocument(server string) map[string]any {
+	schemas := map[string]any{}
+	schemaFor(reflect.TypeOf(Problem{}), schemas)
+
+	paths := map[string]any{}
+	for _, operation := range apiOperations {
+		item, ok := paths[operation.Path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[operation.Path] = item
+		}
+		item[strings.ToLower(operation.Method)] = operation.document(schemas)
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":       "Hot Coffee API",
+			"version":     apiVersion,
+			"description": "Inventory, menu, orders and reports of a coffee shop. Errors are problem details (RFC 7807).",
+		},
+		"servers":  []any{map[string]any{"url": server}},
+		"security": []any{map[string]any{"bearer": []string{}}, map[string]any{"apiKey": []string{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+func (operation apiOperation) document(schemas map[string]any) map[string]any {
+	var parameters []any
+	for _, name := range pathParameters(operation.Path) {
+		parameterType := "string"
+		if name != "product_id" && (strings.HasPrefix(operation.Path, "/orders") || strings.HasPrefix(operation.Path, "/customers") ||
+			strings.HasPrefix(operation.Path, "/staff") || strings.HasPrefix(operation.Path, "/shifts")) {
+			parameterType = "integer"
+		}
+		parameters = append(parameters, map[string]any{
+			"name": name, "in": "path", "required": true, "schema": map[string]any{"type": parameterType},
+		})
+	}
+	for _, parameter := range operation.Query {
+		parameters = append(parameters, map[string]any{
+			"name": parameter.Name, "in": "query", "description": parameter.Description,
+			"schema": map[string]any{"type": parameter.Type},
+		})
+	}
+
+	success := map[string]any{"description": http.StatusText(operation.Status)}
+	if operation.Response != nil {
+		responseType := operation.ResponseType
+		if responseType == "" {
+			responseType = contentTypeJSON
+		}
+		success["content"] = map[string]any{
+			responseType: map[string]any{"schema": schemaOfValue(operation.Response, schemas)},
+		}
+	}
+	if operation.Status == http.StatusCreated {
+		success["headers"] = map[string]any{
+			"Location": map[string]any{"description": "URL of the created resource", "schema": map[string]any{"type": "string"}},
+		}
+	}
+
+	document := map[string]any{
+		"tags":        []string{operation.Tag},
+		"summary":     operation.Summary,
+		"operationId": operationID(operation),
+		"responses": map[string]any{
+			fmt.Sprint(operation.Status): success,
+			"default": map[string]any{
+				"description": "Problem details of the error",
+				"content": map[string]any{
+					"application/problem+json": map[string]any{"schema": map[string]any{"$ref": problemSchemaRef}},
+				},
+			},
+		},
+	}
+	description := operation.Description
+	if role := requiredRole(operation.Method + " " + operation.Path); role == "" {
+		document["security"] = []any{}
+	} else if role == service.RoleMetrics {
+		description = strings.TrimSpace(description + " Requires an API key with the metrics role, or the manager role or above.")
+	} else {
+		description = strings.TrimSpace(description + " Requires an API key with the " + role + " role or above.")
+	}
+	if description != "" {
+		document["description"] = description
+	}
+	if len(parameters) > 0 {
+		document["parameters"] = parameters
+	}
+	if operation.Request != nil {
+		content := map[string]any{}
+		for _, contentType := range operation.RequestTypes {
+			schema := schemaOfValue(operation.Request, schemas)
+			switch contentType {
+			case contentTypeJSONPatch:
+				schema = jsonPatchSchema
+			case contentTypeCSV:
+				schema = map[string]any{"type": "string"}
+			}
+			content[contentType] = map[string]any{"schema": schema}
+		}
+		document["requestBody"] = map[string]any{"required": true, "content": content}
+	}
+	return document
+}
+
+// operationID turns "GET /orders/{id}/payments" into getOrdersIdPayments
+func operationID(operation apiOperation) string {
+	id := strings.ToLower(operation.Method)
+	for _, part := range strings.FieldsFunc(operation.Path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '_' || r == '.'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func pathParameters(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, strings.Trim(segment, "{}"))
+		}
+	}
+	return names
+}
+
+func schemaOfValue(value any, schemas map[string]any) any {
+	if schema, ok := value.(map[string]any); ok {
+		return schema
+	}
+	return schemaFor(reflect.TypeOf(value), schemas)
+}
+
+// schemaFor returns the JSON schema of a Go type as encoding/json would write it. Structs
+// are added to schemas under their type name and referenced.
+func schemaFor(t reflect.Type, schemas map[string]any) map[string]any {
+	switch {
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return map[string]any{}
+	case t == reflect.TypeOf(time.Time{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := map[string]any{"$ref": schemaRefPrefix + t.Name()}
+		if _, exists := schemas[t.Name()]; exists {
+			return ref
+		}
+		// Register the name first so that recursive types end in a reference
+		schemas[t.Name()] = nil
+		properties := map[string]any{}
+		addProperties(t, properties, schemas)
+		schemas[t.Name()] = map[string]any{"type": "object", "properties": properties}
+		return ref
+	default:
+		return map[string]any{}
+	}
+}
+
+// addProperties adds the JSON fields of the struct type to properties. The fields of embedded
+// structs without a JSON name are merged in, as encoding/json does, unless a field of the outer
+// struct has the same name.
+func addProperties(t reflect.Type, properties map[string]any, schemas map[string]any) {
+	promoted := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		embedded := field.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			addProperties(embedded, promoted, schemas)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaFor(field.Type, schemas)
+	}
+	for name, schema := range promoted {
+		if _, exists := properties[name]; !exists {
+			properties[name] = schema
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"hot-cofee/models"
+)
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	mux := NewRouter()
+	VersionEndpoints(mux)
+	if err := CheckOpenAPI(mux); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditCoversEveryChange(t *testing.T) {
+	mux := NewRouter()
+	VersionEndpoints(mux)
+	if err := CheckAudit(mux); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAPIDocumentIsJSON(t *testing.T) {
+	if _, err := json.Marshal(openAPIDocument("http://localhost:8080")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAPISchemaMergesEmbeddedStructs(t *testing.T) {
+	schemas := map[string]any{}
+	schemaFor(reflect.TypeOf(models.IssuedAPIKey{}), schemas)
+	schema, _ := schemas["IssuedAPIKey"].(map[string]any)
+	properties, _ := schema["properties"].(map[string]any)
+	var names []string
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expected := []string{"created_at", "hash", "key", "key_id", "name", "role", "staff_id"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("got properties %v, expected %v", names, expected)
+	}
+	if _, exists := schemas["APIKey"]; exists {
+		t.Fatalf("the embedded APIKey got a schema of its own")
+	}
+}
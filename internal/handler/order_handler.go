@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"hot-cofee/internal/service"
 	"hot-cofee/models"
@@ -14,9 +15,9 @@ import (
 
 var OrderService = service.NewOrderService()
 
-func OrderEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("POST /orders", PostOrderHandler)
-	mux.HandleFunc("POST /orders/", PostOrderHandler)
+func OrderEndpoints(mux *Router) {
+	mux.HandleFunc("POST /orders", withIdempotencyKey(PostOrderHandler))
+	mux.HandleFunc("POST /orders/", withIdempotencyKey(PostOrderHandler))
 
 	mux.HandleFunc("GET /orders", GetAllOrdersHandler)
 	mux.HandleFunc("GET /orders/", GetAllOrdersHandler)
@@ -27,21 +28,65 @@ func OrderEndpoints(mux *http.ServeMux) {
 	mux.HandleFunc("PUT /orders/{id}", PutOrderHandler)
 	mux.HandleFunc("PUT /orders/{id}/", PutOrderHandler)
 
+	mux.HandleFunc("PATCH /orders/{id}", PatchOrderHandler)
+	mux.HandleFunc("PATCH /orders/{id}/", PatchOrderHandler)
+
 	mux.HandleFunc("DELETE /orders/{id}", DeleteOrderByIDHandler)
 	mux.HandleFunc("DELETE /orders/{id}/", DeleteOrderByIDHandler)
 
 	mux.HandleFunc("POST /orders/{id}/close", PostOrderCloserHandler)
 	mux.HandleFunc("POST /orders/{id}/close/", PostOrderCloserHandler)
+
+	mux.HandleFunc("POST /orders/{id}/payments", PostOrderPaymentHandler)
+	mux.HandleFunc("POST /orders/{id}/payments/", PostOrderPaymentHandler)
+
+	mux.HandleFunc("GET /orders/{id}/payments", GetOrderPaymentsHandler)
+	mux.HandleFunc("GET /orders/{id}/payments/", GetOrderPaymentsHandler)
+
+	mux.HandleFunc("GET /orders/{id}/payments/{payment_id}", GetOrderPaymentByIDHandler)
+	mux.HandleFunc("GET /orders/{id}/payments/{payment_id}/", GetOrderPaymentByIDHandler)
+
+	mux.HandleFunc("POST /orders/{id}/refunds", PostOrderRefundHandler)
+	mux.HandleFunc("POST /orders/{id}/refunds/", PostOrderRefundHandler)
+
+	mux.HandleFunc("GET /orders/{id}/refunds", GetOrderRefundsHandler)
+	mux.HandleFunc("GET /orders/{id}/refunds/", GetOrderRefundsHandler)
+
+	mux.HandleFunc("GET /orders/{id}/refunds/{refund_id}", GetOrderRefundByIDHandler)
+	mux.HandleFunc("GET /orders/{id}/refunds/{refund_id}/", GetOrderRefundByIDHandler)
+
+	mux.HandleFunc("GET /orders/{id}/receipt", GetOrderReceiptHandler)
+	mux.HandleFunc("GET /orders/{id}/receipt/", GetOrderReceiptHandler)
+
+	mux.HandleFunc("PUT /orders/{id}/items/{product_id}/status", PutOrderItemStatusHandler)
+	mux.HandleFunc("PUT /orders/{id}/items/{product_id}/status/", PutOrderItemStatusHandler)
+
+	mux.HandleFunc("POST /orders/{id}/items/{product_id}/advance", PostOrderItemAdvanceHandler)
+	mux.HandleFunc("POST /orders/{id}/items/{product_id}/advance/", PostOrderItemAdvanceHandler)
 }
 
 func GetAllOrdersHandler(w http.ResponseWriter, r *http.Request) {
-	orders, err := OrderService.GetAllOrders()
+	filter, err := parseOrderFilter(r)
 	if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	orders, total, err := OrderService.GetOrders(filter)
+	if errors.Is(err, service.ErrOrderNotRead) {
 		ErrorResponse(w, "Could not retrieve orders data", http.StatusInternalServerError)
 		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	if estimates, err := service.EstimateReadyTimes(); err == nil {
+		for i := range orders {
+			orders[i].EstimatedReadyAt = estimates[orders[i].ID]
+		}
 	}
 	// Set response headers
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	w.WriteHeader(http.StatusOK)
 
 	// Marshal the items with indentation
@@ -55,7 +100,7 @@ func GetAllOrdersHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err = w.Write(jsonData); err != nil {
 		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
 	}
-	slog.Info("Retrieved all orders")
+	slog.InfoContext(r.Context(), "Retrieved all orders")
 }
 
 func GetOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
@@ -63,17 +108,22 @@ func GetOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 	ID, err := strconv.Atoi(idString)
 	if err != nil {
 		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
 	}
 	order, err := OrderService.GetOrderByID(ID)
 	if errors.Is(err, service.ErrOrderNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+		ServiceError(w, err, http.StatusNotFound)
 		return
 	}
+	if estimates, err := service.EstimateReadyTimes(); err == nil {
+		order.EstimatedReadyAt = estimates[order.ID]
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(order.Version))
 	w.WriteHeader(http.StatusOK)
 	jsonData, err := json.MarshalIndent(order, "", "    ")
 	if err != nil {
@@ -84,31 +134,32 @@ func GetOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err = w.Write(jsonData); err != nil {
 		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
 	}
-	slog.Info("Retrieved order", "ID", order.ID)
+	slog.InfoContext(r.Context(), "Retrieved order", "ID", order.ID)
 }
 
 func PostOrderHandler(w http.ResponseWriter, r *http.Request) {
 	order, err := parseOrder(r)
 	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	if err = OrderService.AddNewOrder(order); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
+	order.CreatedBy = staffIDFrom(r)
+	order, err = OrderService.AddNewOrder(r.Context(), order)
+	if errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	if _, err = w.Write([]byte("Order added successfully")); err != nil {
-		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
-	}
-	slog.Info("Added new order", "ID", order.ID)
+	w.Header().Set("ETag", etag(order.Version))
+	writeCreated(w, r, fmt.Sprintf("/orders/%d", order.ID), order)
+	slog.InfoContext(r.Context(), "Added new order", "ID", order.ID, "estimated_ready_at", order.EstimatedReadyAt)
 }
 
 func PostOrderCloserHandler(w http.ResponseWriter, r *http.Request) {
@@ -116,70 +167,510 @@ func PostOrderCloserHandler(w http.ResponseWriter, r *http.Request) {
 	idString := r.PathValue("id")
 	ID, err := strconv.Atoi(idString)
 	if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
-	if err := ordersStruct.CloseOrder(ID); errors.Is(err, service.ErrNotExists) {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+	if err := ordersStruct.CloseOrder(r.Context(), ID, staffIDFrom(r)); errors.Is(err, service.ErrNotExists) {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	} else if errors.Is(err, service.ErrOrderNotPaid) {
+		ServiceError(w, err, http.StatusConflict)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
-
-	w.WriteHeader(http.StatusCreated)
-	if _, err := w.Write([]byte("Order closed successfully")); err != nil {
-		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+	order, err := OrderService.GetOrderByID(ID)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
 	}
-	slog.Info("Closed order", "ID", idString)
+	w.Header().Set("ETag", etag(order.Version))
+	writeJSON(w, r, http.StatusOK, order)
+	slog.InfoContext(r.Context(), "Closed order", "ID", idString)
 }
 
 func PutOrderHandler(w http.ResponseWriter, r *http.Request) {
 	order, err := parseOrder(r)
 	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 	idString := r.PathValue("id")
 	ID, err := strconv.Atoi(idString)
 	if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	current, _ := OrderService.GetOrderByID(ID)
+	if err = OrderService.ModifyOrder(r.Context(), order, ID, expectedVersion(r, current.Version)); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil && !errors.Is(err, service.ErrNothingToModify) {
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
-	if err = OrderService.ModifyOrder(order, ID); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
+	order, err = OrderService.GetOrderByID(ID)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(order.Version))
+	writeJSON(w, r, http.StatusOK, order)
+	slog.InfoContext(r.Context(), "Updated order", "ID", order.ID)
+}
+
+func PatchOrderHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	current, err := OrderService.GetOrderByID(ID)
+	if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusNotFound)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
-	if _, err = w.Write([]byte("Order is updated successfully")); err != nil {
-		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+	var order models.Order
+	err = patchResource(r, current, &order)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if errors.Is(err, ErrPatchTestFailed) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if err = OrderService.PatchOrder(r.Context(), order, ID, patchVersion(r, current.Version)); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil && !errors.Is(err, service.ErrNothingToModify) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	order, err = OrderService.GetOrderByID(ID)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
 	}
-	slog.Info("Updated order", "ID", order.ID)
+	w.Header().Set("ETag", etag(order.Version))
+	writeJSON(w, r, http.StatusOK, order)
+	slog.InfoContext(r.Context(), "Patched order", "ID", ID)
 }
 
 func DeleteOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 	idString := r.PathValue("id")
 	ID, err := strconv.Atoi(idString)
 	if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		ServiceError(w, err, http.StatusBadRequest)
 		return
 	}
-	err = OrderService.DeleteOrder(ID)
+	current, _ := OrderService.GetOrderByID(ID)
+	err = OrderService.DeleteOrder(r.Context(), ID, expectedVersion(r, current.Version))
 	if errors.Is(err, service.ErrOrderNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	} else if errors.Is(err, service.ErrOrderHasPayments) {
+		ServiceError(w, err, http.StatusConflict)
 		return
 	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		ServiceError(w, err, http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
-	slog.Info("Deleted order", "ID", idString)
+	slog.InfoContext(r.Context(), "Deleted order", "ID", idString)
+}
+
+func PostOrderPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	payment, err := parsePayment(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	payment, err = OrderService.AddPayment(r.Context(), ID, payment)
+	if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeCreated(w, r, fmt.Sprintf("/orders/%d/payments/%d", ID, payment.ID), payment)
+	slog.InfoContext(r.Context(), "Added payment", "order", ID, "method", payment.Method, "amount", payment.Amount)
+}
+
+func GetOrderPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	order, err := OrderService.GetOrderByID(ID)
+	if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	payments := order.Payments
+	if payments == nil {
+		payments = []models.Payment{}
+	}
+	writeJSON(w, r, http.StatusOK, payments)
+	slog.InfoContext(r.Context(), "Retrieved order payments", "order", ID)
+}
+
+func GetOrderPaymentByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	paymentID, err := strconv.Atoi(r.PathValue("payment_id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+	order, err := OrderService.GetOrderByID(ID)
+	if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	for _, payment := range order.Payments {
+		if payment.ID == paymentID {
+			writeJSON(w, r, http.StatusOK, payment)
+			slog.InfoContext(r.Context(), "Retrieved order payment", "order", ID, "payment", paymentID)
+			return
+		}
+	}
+	ErrorResponse(w, fmt.Sprintf("payment %d of order %d not found", paymentID, ID), http.StatusNotFound)
+}
+
+func parsePayment(r *http.Request) (models.Payment, error) {
+	var payment models.Payment
+	contentType := r.Header.Get("Content-Type")
+
+	if contentType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+			return payment, fmt.Errorf("invalid JSON payload")
+		}
+	} else if contentType == "application/x-www-form-urlencoded" {
+		if err := r.ParseForm(); err != nil {
+			return payment, fmt.Errorf("invalid form data")
+		}
+		payment = models.Payment{
+			Method:    r.FormValue("method"),
+			Reference: r.FormValue("reference"),
+		}
+		for name, target := range map[string]*float64{
+			"amount":   &payment.Amount,
+			"tendered": &payment.Tendered,
+			"tip":      &payment.Tip,
+		} {
+			if value := r.FormValue(name); value != "" {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return payment, fmt.Errorf("%s is not a float", name)
+				}
+				*target = parsed
+			}
+		}
+	} else {
+		return payment, ErrUnsupportedContentType
+	}
+
+	// Change is computed by the service, never taken from the client
+	payment.Change = 0
+	return payment, nil
+}
+
+func PostOrderRefundHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	refund, err := parseRefund(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	refund, err = OrderService.RefundOrder(r.Context(), ID, staffIDFrom(r), refund)
+	if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if errors.Is(err, service.ErrOrderNotClosed) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeCreated(w, r, fmt.Sprintf("/orders/%d/refunds/%d", ID, refund.ID), refund)
+	slog.InfoContext(r.Context(), "Refunded order", "order", ID, "amount", refund.Amount, "reason", refund.Reason)
+}
+
+func GetOrderRefundsHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	order, err := OrderService.GetOrderByID(ID)
+	if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	refunds := order.Refunds
+	if refunds == nil {
+		refunds = []models.Refund{}
+	}
+	writeJSON(w, r, http.StatusOK, refunds)
+	slog.InfoContext(r.Context(), "Retrieved order refunds", "order", ID)
+}
+
+func GetOrderRefundByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	refundID, err := strconv.Atoi(r.PathValue("refund_id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid refund ID", http.StatusBadRequest)
+		return
+	}
+	order, err := OrderService.GetOrderByID(ID)
+	if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	for _, refund := range order.Refunds {
+		if refund.ID == refundID {
+			writeJSON(w, r, http.StatusOK, refund)
+			slog.InfoContext(r.Context(), "Retrieved order refund", "order", ID, "refund", refundID)
+			return
+		}
+	}
+	ErrorResponse(w, fmt.Sprintf("refund %d of order %d not found", refundID, ID), http.StatusNotFound)
+}
+
+func GetOrderReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	receipt, contentType, err := service.RenderReceipt(ID, format)
+	if errors.Is(err, service.ErrUnsupportedReceiptFormat) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	} else if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if format == service.ReceiptESCPOS {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"receipt-%d.bin\"", ID))
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(receipt); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write receipt", "error", err)
+	}
+	slog.InfoContext(r.Context(), "Printed receipt", "ID", ID, "format", format)
+}
+
+func PutOrderItemStatusHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	status, err := parseItemStatus(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	productID := r.PathValue("product_id")
+	order, err := OrderService.SetItemStatus(r.Context(), ID, productID, status)
+	if errors.Is(err, service.ErrNothingToModify) {
+		// Setting the status a line already has leaves the order as it is
+		order, err = OrderService.GetOrderByID(ID)
+	}
+	if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("ETag", etag(order.Version))
+	writeJSON(w, r, http.StatusOK, order)
+	slog.InfoContext(r.Context(), "Updated order item status", "order", ID, "product", productID, "status", status)
+}
+
+func PostOrderItemAdvanceHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	productID := r.PathValue("product_id")
+	order, err := OrderService.AdvanceItem(r.Context(), ID, productID)
+	if errors.Is(err, service.ErrOrderNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("ETag", etag(order.Version))
+	writeJSON(w, r, http.StatusOK, order)
+	slog.InfoContext(r.Context(), "Advanced order item", "order", ID, "product", productID, "status", order.Status)
+}
+
+func parseItemStatus(r *http.Request) (string, error) {
+	var body struct {
+		Status string `json:"status"`
+	}
+	contentType := r.Header.Get("Content-Type")
+
+	if contentType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return "", fmt.Errorf("invalid JSON payload")
+		}
+	} else if contentType == "application/x-www-form-urlencoded" {
+		if err := r.ParseForm(); err != nil {
+			return "", fmt.Errorf("invalid form data")
+		}
+		body.Status = r.FormValue("status")
+	} else {
+		return "", ErrUnsupportedContentType
+	}
+
+	return body.Status, nil
+}
+
+func parseRefund(r *http.Request) (models.Refund, error) {
+	var refund models.Refund
+	contentType := r.Header.Get("Content-Type")
+
+	if contentType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&refund); err != nil {
+			return refund, fmt.Errorf("invalid JSON payload")
+		}
+	} else if contentType == "application/x-www-form-urlencoded" {
+		if err := r.ParseForm(); err != nil {
+			return refund, fmt.Errorf("invalid form data")
+		}
+		var items []models.RefundItem
+		if err := json.Unmarshal([]byte(r.FormValue("items")), &items); err != nil {
+			return refund, fmt.Errorf("error parsing items: %v", err)
+		}
+		refund = models.Refund{
+			Items:   items,
+			Reason:  r.FormValue("reason"),
+			Restock: r.FormValue("restock") == "true",
+		}
+	} else {
+		return refund, ErrUnsupportedContentType
+	}
+
+	return refund, nil
+}
+
+// parseOrderFilter reads the status, customer, customer_id, from, to, sort, limit and offset query parameters
+func parseOrderFilter(r *http.Request) (models.OrderFilter, error) {
+	query := r.URL.Query()
+	filter := models.OrderFilter{
+		Status:   query.Get("status"),
+		Customer: query.Get("customer"),
+		Sort:     query.Get("sort"),
+	}
+	var err error
+	if customerID := query.Get("customer_id"); customerID != "" {
+		if filter.CustomerID, err = strconv.Atoi(customerID); err != nil {
+			return filter, fmt.Errorf("customer_id is not an integer")
+		}
+	}
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = parseOrderTime(from, false); err != nil {
+			return filter, fmt.Errorf("invalid from: %s", from)
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = parseOrderTime(to, true); err != nil {
+			return filter, fmt.Errorf("invalid to: %s", to)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return filter, fmt.Errorf("limit is not an integer")
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil {
+			return filter, fmt.Errorf("offset is not an integer")
+		}
+	}
+	return filter, nil
+}
+
+// parseOrderTime accepts either a full "2006-01-02 15:04:05" timestamp or a bare date.
+// A bare date used as an upper bound covers the whole day.
+func parseOrderTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateTime, value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return t, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
 }
 
 func parseOrder(r *http.Request) (models.Order, error) {
@@ -200,21 +691,36 @@ func parseOrder(r *http.Request) (models.Order, error) {
 		if err != nil {
 			return order, fmt.Errorf("ID is not an integer")
 		}
+		customerID := 0
+		if value := r.FormValue("customer_id"); value != "" {
+			if customerID, err = strconv.Atoi(value); err != nil {
+				return order, fmt.Errorf("customer ID is not an integer")
+			}
+		}
 		var items []models.OrderItem
 		itemsJson := r.FormValue("items")
 		if err := json.Unmarshal([]byte(itemsJson), &items); err != nil {
 			return order, fmt.Errorf("error parsing ingredients: %v", err)
 		}
+		var rewards []models.AppliedReward
+		if rewardsJson := r.FormValue("rewards"); rewardsJson != "" {
+			if err := json.Unmarshal([]byte(rewardsJson), &rewards); err != nil {
+				return order, fmt.Errorf("error parsing rewards: %v", err)
+			}
+		}
 
 		order = models.Order{
 			ID:           ID,
+			CustomerID:   customerID,
 			CustomerName: r.FormValue("customer_name"),
 			Items:        items,
 			Status:       r.FormValue("status"),
 			CreatedAt:    r.FormValue("created_at"),
+			PromoCode:    r.FormValue("promo_code"),
+			Rewards:      rewards,
 		}
 	} else {
-		return order, fmt.Errorf("unsupported content type")
+		return order, ErrUnsupportedContentType
 	}
 
 	return order, nil
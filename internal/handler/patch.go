@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+var ErrPatchTestFailed = errors.New("patch test operation failed")
+
+// jsonPatchOperation is one operation of a JSON Patch document (RFC 6902)
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// patchResource applies the JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) in the
+// request body to the JSON form of current and decodes the result into patched
+func patchResource(r *http.Request, current, patched any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != contentTypeMergePatch && mediaType != contentTypeJSONPatch) {
+		return ErrUnsupportedContentType
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return errors.New("failed to read patch")
+	}
+	document, err := toJSONValue(current)
+	if err != nil {
+		return err
+	}
+
+	if mediaType == contentTypeMergePatch {
+		patch, err := decodeJSONValue(body)
+		if err != nil {
+			return fmt.Errorf("invalid merge patch: %v", err)
+		}
+		document = applyMergePatch(document, patch)
+	} else {
+		var operations []jsonPatchOperation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			return fmt.Errorf("invalid JSON patch: %v", err)
+		}
+		for i, operation := range operations {
+			if document, err = applyJSONPatchOperation(document, operation); err != nil {
+				return fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+			}
+		}
+	}
+
+	result, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(result))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(patched); err != nil {
+		return fmt.Errorf("patched resource is invalid: %v", err)
+	}
+	return nil
+}
+
+func toJSONValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONValue(data)
+}
+
+// decodeJSONValue keeps numbers as json.Number so that patching does not round them
+func decodeJSONValue(data []byte) (any, error) {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// applyMergePatch merges the patch into the target: null removes a member,
+// objects are merged recursively and any other value replaces the target
+func applyMergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = applyMergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+func applyJSONPatchOperation(document any, operation jsonPatchOperation) (any, error) {
+	var value any
+	if operation.Value != nil {
+		var err error
+		if value, err = decodeJSONValue(*operation.Value); err != nil {
+			return nil, err
+		}
+	}
+	switch operation.Op {
+	case "add":
+		if operation.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		return addJSONValue(document, operation.Path, value)
+	case "remove":
+		document, _, err := removeJSONValue(document, operation.Path)
+		return document, err
+	case "replace":
+		if operation.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		document, _, err := removeJSONValue(document, operation.Path)
+		if err != nil {
+			return nil, err
+		}
+		return addJSONValue(document, operation.Path, value)
+	case "move":
+		if strings.HasPrefix(operation.Path, operation.From+"/") {
+			return nil, errors.New("cannot move a value into itself")
+		}
+		document, moved, err := removeJSONValue(document, operation.From)
+		if err != nil {
+			return nil, err
+		}
+		return addJSONValue(document, operation.Path, moved)
+	case "copy":
+		copied, err := getJSONValue(document, operation.From)
+		if err != nil {
+			return nil, err
+		}
+		// Copy through JSON so that the two places do not share maps or slices
+		if copied, err = toJSONValue(copied); err != nil {
+			return nil, err
+		}
+		return addJSONValue(document, operation.Path, copied)
+	case "test":
+		if operation.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		actual, err := getJSONValue(document, operation.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(normalizeJSONValue(actual), normalizeJSONValue(value)) {
+			return nil, ErrPatchTestFailed
+		}
+		return document, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", operation.Op)
+	}
+}
+
+// splitJSONPointer turns a JSON Pointer (RFC 6901) into its unescaped reference tokens
+func splitJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	// An index is "0" or digits without a leading zero, no sign
+	if token == "" || strings.Trim(token, "0123456789") != "" || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > length || (!allowEnd && index == length) {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+func getJSONValue(document any, pointer string) (any, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	current := document
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]any:
+			value, exists := node[token]
+			if !exists {
+				return nil, fmt.Errorf("path %q does not exist", pointer)
+			}
+			current = value
+		case []any:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("path %q does not exist", pointer)
+		}
+	}
+	return current, nil
+}
+
+// addJSONValue inserts the value at the pointer and returns the new document,
+// which is only a different value when the whole document is replaced
+func addJSONValue(document any, pointer string, value any) (any, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return setInContainer(document, tokens, value, pointer)
+}
+
+func setInContainer(container any, tokens []string, value any, pointer string) (any, error) {
+	token := tokens[0]
+	last := len(tokens) == 1
+	switch node := container.(type) {
+	case map[string]any:
+		if last {
+			node[token] = value
+			return node, nil
+		}
+		child, exists := node[token]
+		if !exists {
+			return nil, fmt.Errorf("path %q does not exist", pointer)
+		}
+		updated, err := setInContainer(child, tokens[1:], value, pointer)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = updated
+		return node, nil
+	case []any:
+		index, err := arrayIndex(token, len(node), last)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			// A new slice, so that the array read from the document is left as it was
+			return slices.Concat(node[:index], []any{value}, node[index:]), nil
+		}
+		updated, err := setInContainer(node[index], tokens[1:], value, pointer)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = updated
+		return node, nil
+	default:
+		return nil, fmt.Errorf("path %q does not exist", pointer)
+	}
+}
+
+// removeJSONValue deletes the value at the pointer and returns the new document and the removed value
+func removeJSONValue(document any, pointer string) (any, any, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, document, nil
+	}
+	return removeFromContainer(document, tokens, pointer)
+}
+
+func removeFromContainer(container any, tokens []string, pointer string) (any, any, error) {
+	token := tokens[0]
+	last := len(tokens) == 1
+	switch node := container.(type) {
+	case map[string]any:
+		child, exists := node[token]
+		if !exists {
+			return nil, nil, fmt.Errorf("path %q does not exist", pointer)
+		}
+		if last {
+			delete(node, token)
+			return node, child, nil
+		}
+		updated, removed, err := removeFromContainer(child, tokens[1:], pointer)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = updated
+		return node, removed, nil
+	case []any:
+		index, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			// A new slice, which stays [] rather than null when the last element is removed
+			updated := make([]any, 0, len(node)-1)
+			updated = append(updated, node[:index]...)
+			return append(updated, node[index+1:]...), node[index], nil
+		}
+		updated, removed, err := removeFromContainer(node[index], tokens[1:], pointer)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[index] = updated
+		return node, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("path %q does not exist", pointer)
+	}
+}
+
+// normalizeJSONValue compares numbers by value, so that 2 equals 2.0 in a test operation
+func normalizeJSONValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any:
+		normalized := make(map[string]any, len(v))
+		for key, val := range v {
+			normalized[key] = normalizeJSONValue(val)
+		}
+		return normalized
+	case []any:
+		normalized := make([]any, len(v))
+		for i, val := range v {
+			normalized[i] = normalizeJSONValue(val)
+		}
+		return normalized
+	default:
+		return value
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func mustDecodeJSON(t *testing.T, data string) any {
+	t.Helper()
+	value, err := decodeJSONValue([]byte(data))
+	if err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	return value
+}
+
+// applyJSONPatch applies a whole JSON Patch document the way patchResource does
+func applyJSONPatch(t *testing.T, document, patch string) (any, error) {
+	t.Helper()
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal([]byte(patch), &operations); err != nil {
+		t.Fatalf("invalid patch %s: %v", patch, err)
+	}
+	result := mustDecodeJSON(t, document)
+	for _, operation := range operations {
+		var err error
+		if result, err = applyJSONPatchOperation(result, operation); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		patch    string
+		// expected is the patched document, "" when the patch must fail
+		expected string
+	}{
+		// RFC 6902, Appendix A
+		{"A.1 add an object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"A.2 add an array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"A.3 remove an object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"A.4 remove an array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"A.5 replace a value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"A.6 move a value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"A.7 move an array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"A.8 test a value: success", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"A.9 test a value: error", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ""},
+		{"A.10 add a nested member object", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"A.11 ignore unrecognized elements", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`},
+		{"A.12 add to a nonexistent target", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ""},
+		{"A.13 invalid JSON patch document", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","op":"remove"}]`, ""},
+		{"A.14 ~ escape ordering", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		{"A.15 compare strings and numbers", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ""},
+		{"A.16 add an array value", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+
+		// Edge cases
+		{"move into its own child", `{"a":{"b":{}}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, ""},
+		{"move onto itself", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a"}]`, `{"a":{"b":1}}`},
+		{"move to a sibling with a longer name", `{"a":1}`, `[{"op":"move","from":"/a","path":"/ab"}]`, `{"ab":1}`},
+		{"replace - is out of range", `{"a":[1,2]}`, `[{"op":"replace","path":"/a/-","value":3}]`, ""},
+		{"remove - is out of range", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/-"}]`, ""},
+		{"add at the end by index", `{"a":[1,2]}`, `[{"op":"add","path":"/a/2","value":3}]`, `{"a":[1,2,3]}`},
+		{"add past the end", `{"a":[1,2]}`, `[{"op":"add","path":"/a/3","value":3}]`, ""},
+		{"leading zero index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`, ""},
+		{"signed index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/+1"}]`, ""},
+		{"negative zero index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/-0"}]`, ""},
+		{"zero index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/0"}]`, `{"a":[2]}`},
+		{"remove the last element", `{"a":[1]}`, `[{"op":"remove","path":"/a/0"}]`, `{"a":[]}`},
+		{"~01 names ~1, not /", `{"~1":1,"/":2}`, `[{"op":"remove","path":"/~01"}]`, `{"/":2}`},
+		{"~10 names /0", `{"/0":1,"~0":2}`, `[{"op":"remove","path":"/~10"}]`, `{"~0":2}`},
+		{"test a number written differently", `{"a":2}`, `[{"op":"test","path":"/a","value":2.0}]`, `{"a":2}`},
+		{"test a number in exponent form", `{"a":1.5}`, `[{"op":"test","path":"/a","value":15e-1}]`, `{"a":1.5}`},
+		{"test a different number", `{"a":2}`, `[{"op":"test","path":"/a","value":2.5}]`, ""},
+		{"test a number nested in an array", `{"a":[{"b":1}]}`, `[{"op":"test","path":"/a","value":[{"b":1.0}]}]`, `{"a":[{"b":1}]}`},
+		{"test a missing path", `{"a":1}`, `[{"op":"test","path":"/b","value":1}]`, ""},
+		{"copy does not share values", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`},
+		{"replace the whole document", `{"a":1}`, `[{"op":"replace","path":"","value":{"b":2}}]`, `{"b":2}`},
+		{"missing value", `{"a":1}`, `[{"op":"add","path":"/b"}]`, ""},
+		{"unknown operation", `{"a":1}`, `[{"op":"increment","path":"/a"}]`, ""},
+		{"path without leading slash", `{"a":1}`, `[{"op":"remove","path":"a"}]`, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := applyJSONPatch(t, test.document, test.patch)
+			if test.expected == "" {
+				if err == nil {
+					t.Fatalf("patch succeeded with %v, expected an error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("patch failed: %v", err)
+			}
+			expected := mustDecodeJSON(t, test.expected)
+			if !reflect.DeepEqual(normalizeJSONValue(result), normalizeJSONValue(expected)) {
+				t.Fatalf("got %v, expected %v", result, expected)
+			}
+		})
+	}
+}
+
+func TestJSONPatchTestFailure(t *testing.T) {
+	_, err := applyJSONPatch(t, `{"a":1}`, `[{"op":"test","path":"/a","value":2}]`)
+	if !errors.Is(err, ErrPatchTestFailed) {
+		t.Fatalf("got %v, expected ErrPatchTestFailed", err)
+	}
+}
+
+func TestJSONPatchLeavesArraysOfTheDocument(t *testing.T) {
+	document := mustDecodeJSON(t, `{"a":[1,2,3]}`)
+	array := document.(map[string]any)["a"].([]any)
+	if _, _, err := removeJSONValue(document, "/a/0"); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if _, err := addJSONValue(document, "/a/0", json.Number("0")); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	expected := []any{json.Number("1"), json.Number("2"), json.Number("3")}
+	if !reflect.DeepEqual(array, expected) {
+		t.Fatalf("the array read before patching became %v", array)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// RFC 7396, Appendix A
+	tests := []struct {
+		target, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.target+" "+test.patch, func(t *testing.T) {
+			result := applyMergePatch(mustDecodeJSON(t, test.target), mustDecodeJSON(t, test.patch))
+			expected := mustDecodeJSON(t, test.expected)
+			if !reflect.DeepEqual(normalizeJSONValue(result), normalizeJSONValue(expected)) {
+				t.Fatalf("got %v, expected %v", result, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+var PromotionService = service.NewPromotionService()
+
+func PromotionEndpoints(mux *Router) {
+	mux.HandleFunc("POST /promotions", PostPromotionHandler)
+	mux.HandleFunc("POST /promotions/", PostPromotionHandler)
+
+	mux.HandleFunc("GET /promotions", GetAllPromotionsHandler)
+	mux.HandleFunc("GET /promotions/", GetAllPromotionsHandler)
+
+	mux.HandleFunc("GET /promotions/{id}", GetPromotionByIDHandler)
+	mux.HandleFunc("GET /promotions/{id}/", GetPromotionByIDHandler)
+
+	mux.HandleFunc("PUT /promotions/{id}", PutPromotionHandler)
+	mux.HandleFunc("PUT /promotions/{id}/", PutPromotionHandler)
+
+	mux.HandleFunc("DELETE /promotions/{id}", DeletePromotionByIDHandler)
+	mux.HandleFunc("DELETE /promotions/{id}/", DeletePromotionByIDHandler)
+}
+
+func GetAllPromotionsHandler(w http.ResponseWriter, r *http.Request) {
+	promotions, err := PromotionService.GetAllPromotions()
+	if err != nil {
+		ErrorResponse(w, "Could not retrieve promotions data", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, promotions)
+	slog.InfoContext(r.Context(), "Retrieved all promotions")
+}
+
+func GetPromotionByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	promotion, err := PromotionService.GetPromotionByID(id)
+	if errors.Is(err, service.ErrPromotionNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("ETag", etag(promotion.Version))
+	writeJSON(w, r, http.StatusOK, promotion)
+	slog.InfoContext(r.Context(), "Retrieved promotion", "ID", id)
+}
+
+func PostPromotionHandler(w http.ResponseWriter, r *http.Request) {
+	promotion, err := parsePromotion(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if err = PromotionService.AddNewPromotion(r.Context(), promotion); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	if promotion, err = PromotionService.GetPromotionByID(promotion.ID); err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(promotion.Version))
+	writeCreated(w, r, "/promotions/"+url.PathEscape(promotion.ID), promotion)
+	slog.InfoContext(r.Context(), "Added promotion", "ID", promotion.ID)
+}
+
+func PutPromotionHandler(w http.ResponseWriter, r *http.Request) {
+	promotion, err := parsePromotion(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	id := r.PathValue("id")
+	if id != promotion.ID {
+		ErrorResponse(w, "promotion ID does not match id", http.StatusBadRequest)
+		return
+	}
+
+	current, _ := PromotionService.GetPromotionByID(id)
+	if err = PromotionService.ModifyPromotion(r.Context(), promotion, expectedVersion(r, current.Version)); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	if promotion, err = PromotionService.GetPromotionByID(id); err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(promotion.Version))
+	writeJSON(w, r, http.StatusOK, promotion)
+	slog.InfoContext(r.Context(), "Updated promotion", "ID", id)
+}
+
+func DeletePromotionByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	current, _ := PromotionService.GetPromotionByID(id)
+	if err := PromotionService.DeletePromotion(r.Context(), id, expectedVersion(r, current.Version)); err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	slog.InfoContext(r.Context(), "Deleted promotion", "ID", id)
+}
+
+// parsePromotion only accepts JSON since promotions carry lists of products, categories and days
+func parsePromotion(r *http.Request) (models.Promotion, error) {
+	var promotion models.Promotion
+	if r.Header.Get("Content-Type") != "application/json" {
+		return promotion, ErrUnsupportedContentType
+	}
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		return promotion, errors.New("invalid JSON payload")
+	}
+	return promotion, nil
+}
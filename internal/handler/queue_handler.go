@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"hot-cofee/internal/service"
+)
+
+// keepAliveInterval keeps idle event streams from being closed by proxies
+const keepAliveInterval = 15 * time.Second
+
+func QueueEndpoints(mux *Router) {
+	mux.HandleFunc("GET /queue", GetQueueHandler)
+	mux.HandleFunc("GET /queue/", GetQueueHandler)
+
+	mux.HandleFunc("GET /queue/events", GetQueueEventsHandler)
+	mux.HandleFunc("GET /queue/events/", GetQueueEventsHandler)
+}
+
+func GetQueueHandler(w http.ResponseWriter, r *http.Request) {
+	queue, err := service.GetQueue()
+	if err != nil {
+		ErrorResponse(w, "Could not retrieve queue", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, queue)
+}
+
+// GetQueueEventsHandler streams order events as Server-Sent Events until the client disconnects
+func GetQueueEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		ErrorResponse(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	events, unsubscribe := service.SubscribeOrderEvents()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+	slog.InfoContext(r.Context(), "Queue display connected", "remote", r.RemoteAddr)
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			slog.InfoContext(r.Context(), "Queue display disconnected", "remote", r.RemoteAddr)
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to encode order event", "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
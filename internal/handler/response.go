@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// writeJSON writes the value as indented JSON with the given status code. The request ties
+// the log of a failed write to it.
+func writeJSON(w http.ResponseWriter, r *http.Request, statusCode int, value any) {
+	jsonData, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		ErrorResponse(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if _, err = w.Write(jsonData); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write response", "error", err)
+	}
+}
+
+// writeCreated answers 201 with the created resource and its URL in the Location header. The
+// location is given without the version prefix, which is added from the route of the request so
+// that clients of every version are sent to the resource in the version they use.
+func writeCreated(w http.ResponseWriter, r *http.Request, location string, value any) {
+	w.Header().Set("Location", routePrefix(r)+location)
+	writeJSON(w, r, http.StatusCreated, value)
+}
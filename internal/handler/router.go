@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Router is the mux every endpoint is registered on. It remembers the registered patterns
+// so that the OpenAPI document can be checked against the routes that really exist.
+// Routers made by Group share the mux and the patterns of their parent.
+type Router struct {
+	*http.ServeMux
+	prefix     string
+	middleware func(http.HandlerFunc) http.HandlerFunc
+	patterns   *[]string
+}
+
+func NewRouter() *Router {
+	return &Router{ServeMux: http.NewServeMux(), patterns: &[]string{}}
+}
+
+// Group returns a router that registers its routes on the same mux below the prefix,
+// e.g. "GET /orders" as "GET /v1/orders", wrapping every handler in the middleware if it is set
+func (rt *Router) Group(prefix string, middleware func(http.HandlerFunc) http.HandlerFunc) *Router {
+	return &Router{
+		ServeMux:   rt.ServeMux,
+		prefix:     rt.prefix + prefix,
+		middleware: middleware,
+		patterns:   rt.patterns,
+	}
+}
+
+// HandleFunc registers the handler for the pattern like http.ServeMux does and records the pattern.
+// A path ending in a slash only matches itself, not everything below it like in http.ServeMux,
+// so that e.g. "POST /menu/import/" does not collide with "POST /menu/{id}/restore".
+func (rt *Router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	if rt.prefix != "" {
+		if method, path, found := strings.Cut(pattern, " "); found {
+			pattern = method + " " + rt.prefix + path
+		} else {
+			pattern = rt.prefix + pattern
+		}
+	}
+	if rt.middleware != nil {
+		handler = rt.middleware(handler)
+	}
+	if rt.prefix != "" {
+		handler = withRoutePrefix(rt.prefix, handler)
+	}
+	registered := pattern
+	if _, path, found := strings.Cut(pattern, " "); found && path != "/" && strings.HasSuffix(path, "/") {
+		registered += "{$}"
+	}
+	rt.ServeMux.HandleFunc(registered, handler)
+	*rt.patterns = append(*rt.patterns, pattern)
+}
+
+// routePrefixKey is the context key of the prefix of the group that registered the route
+type routePrefixKey struct{}
+
+func withRoutePrefix(prefix string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(context.WithValue(r.Context(), routePrefixKey{}, prefix)))
+	}
+}
+
+// routePrefix returns the prefix of the group whose route matched the request, e.g. /v1, so that
+// the URLs sent back to the client stay in the version it uses
+func routePrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(routePrefixKey{}).(string)
+	return prefix
+}
+
+// Routes returns the registered "METHOD /path" patterns, sorted and without the variants
+// with a trailing slash. Patterns without a method, like the catch-all, are left out.
+func (rt *Router) Routes() []string {
+	seen := make(map[string]bool)
+	var routes []string
+	for _, pattern := range *rt.patterns {
+		method, path, found := strings.Cut(pattern, " ")
+		if !found {
+			continue
+		}
+		if path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		route := method + " " + path
+		if !seen[route] {
+			seen[route] = true
+			routes = append(routes, route)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+var StaffService = service.NewStaffService()
+
+func StaffEndpoints(mux *Router) {
+	mux.HandleFunc("POST /staff", PostStaffHandler)
+	mux.HandleFunc("POST /staff/", PostStaffHandler)
+
+	mux.HandleFunc("GET /staff", GetAllStaffHandler)
+	mux.HandleFunc("GET /staff/", GetAllStaffHandler)
+
+	mux.HandleFunc("GET /staff/{id}", GetStaffByIDHandler)
+	mux.HandleFunc("GET /staff/{id}/", GetStaffByIDHandler)
+
+	mux.HandleFunc("PUT /staff/{id}", PutStaffHandler)
+	mux.HandleFunc("PUT /staff/{id}/", PutStaffHandler)
+
+	mux.HandleFunc("DELETE /staff/{id}", DeleteStaffByIDHandler)
+	mux.HandleFunc("DELETE /staff/{id}/", DeleteStaffByIDHandler)
+
+	mux.HandleFunc("POST /staff/{id}/clock-in", PostClockInHandler)
+	mux.HandleFunc("POST /staff/{id}/clock-in/", PostClockInHandler)
+
+	mux.HandleFunc("POST /staff/{id}/clock-out", PostClockOutHandler)
+	mux.HandleFunc("POST /staff/{id}/clock-out/", PostClockOutHandler)
+
+	mux.HandleFunc("GET /staff/{id}/shifts", GetStaffShiftsHandler)
+	mux.HandleFunc("GET /staff/{id}/shifts/", GetStaffShiftsHandler)
+
+	mux.HandleFunc("GET /shifts", GetAllShiftsHandler)
+	mux.HandleFunc("GET /shifts/", GetAllShiftsHandler)
+
+	mux.HandleFunc("GET /shifts/{id}", GetShiftByIDHandler)
+	mux.HandleFunc("GET /shifts/{id}/", GetShiftByIDHandler)
+}
+
+func GetAllStaffHandler(w http.ResponseWriter, r *http.Request) {
+	staff, err := StaffService.GetAllStaff()
+	if err != nil {
+		ErrorResponse(w, "Could not retrieve staff data", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, staff)
+	slog.InfoContext(r.Context(), "Retrieved all staff")
+}
+
+func GetStaffByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid staff ID", http.StatusBadRequest)
+		return
+	}
+	staff, err := StaffService.GetStaffByID(ID)
+	if errors.Is(err, service.ErrStaffNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, staff)
+	slog.InfoContext(r.Context(), "Retrieved staff member", "ID", ID)
+}
+
+func PostStaffHandler(w http.ResponseWriter, r *http.Request) {
+	staff, err := parseStaff(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	staff, err = StaffService.AddNewStaff(r.Context(), staff)
+	if errors.Is(err, service.ErrStaffNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeCreated(w, r, fmt.Sprintf("/staff/%d", staff.ID), staff)
+	slog.InfoContext(r.Context(), "Added staff member", "ID", staff.ID)
+}
+
+func PutStaffHandler(w http.ResponseWriter, r *http.Request) {
+	staff, err := parseStaff(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid staff ID", http.StatusBadRequest)
+		return
+	}
+	if staff.ID != 0 && staff.ID != ID {
+		ErrorResponse(w, "staff ID does not match id", http.StatusBadRequest)
+		return
+	}
+	staff.ID = ID
+
+	if err = StaffService.ModifyStaff(r.Context(), staff); err != nil && !errors.Is(err, service.ErrNothingToModify) {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	staff, err = StaffService.GetStaffByID(ID)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, staff)
+	slog.InfoContext(r.Context(), "Updated staff member", "ID", ID)
+}
+
+func DeleteStaffByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid staff ID", http.StatusBadRequest)
+		return
+	}
+	if err = StaffService.DeleteStaff(r.Context(), ID); err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	slog.InfoContext(r.Context(), "Deleted staff member", "ID", ID)
+}
+
+func PostClockInHandler(w http.ResponseWriter, r *http.Request) {
+	ID, ok := shiftStaffID(w, r)
+	if !ok {
+		return
+	}
+	shift, err := StaffService.ClockIn(r.Context(), ID)
+	if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeCreated(w, r, fmt.Sprintf("/shifts/%d", shift.ID), shift)
+	slog.InfoContext(r.Context(), "Clocked in", "staff", ID, "shift", shift.ID)
+}
+
+func PostClockOutHandler(w http.ResponseWriter, r *http.Request) {
+	ID, ok := shiftStaffID(w, r)
+	if !ok {
+		return
+	}
+	shift, err := StaffService.ClockOut(r.Context(), ID)
+	if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, shift)
+	slog.InfoContext(r.Context(), "Clocked out", "staff", ID, "shift", shift.ID)
+}
+
+func GetStaffShiftsHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid staff ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := StaffService.GetStaffByID(ID); errors.Is(err, service.ErrStaffNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	shifts, err := StaffService.GetShifts(ID)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, shifts)
+	slog.InfoContext(r.Context(), "Retrieved shifts", "staff", ID)
+}
+
+func GetAllShiftsHandler(w http.ResponseWriter, r *http.Request) {
+	shifts, err := StaffService.GetShifts(0)
+	if err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, shifts)
+	slog.InfoContext(r.Context(), "Retrieved all shifts")
+}
+
+func GetShiftByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid shift ID", http.StatusBadRequest)
+		return
+	}
+	shift, err := StaffService.GetShiftByID(ID)
+	if errors.Is(err, service.ErrShiftNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, shift)
+	slog.InfoContext(r.Context(), "Retrieved shift", "ID", ID)
+}
+
+// shiftStaffID reads the staff ID of a clock-in or clock-out. Baristas may only clock
+// themselves in and out, managers anybody.
+func shiftStaffID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		ErrorResponse(w, "Invalid staff ID", http.StatusBadRequest)
+		return 0, false
+	}
+	if principal, ok := principalFrom(r.Context()); ok &&
+		!service.RoleAllows(principal.Role, service.RoleManager) && principal.StaffID != ID {
+		ErrorResponse(w, "baristas can only clock themselves in and out", http.StatusForbidden)
+		return 0, false
+	}
+	return ID, true
+}
+
+// parseStaff only accepts JSON
+func parseStaff(r *http.Request) (models.Staff, error) {
+	var staff models.Staff
+	if r.Header.Get("Content-Type") != "application/json" {
+		return staff, ErrUnsupportedContentType
+	}
+	if err := json.NewDecoder(r.Body).Decode(&staff); err != nil {
+		return staff, errors.New("invalid JSON payload")
+	}
+	return staff, nil
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"hot-cofee/internal/service"
+	"hot-cofee/models"
+)
+
+var TaxService = service.NewTaxService()
+
+func TaxEndpoints(mux *Router) {
+	mux.HandleFunc("POST /taxes", PostTaxHandler)
+	mux.HandleFunc("POST /taxes/", PostTaxHandler)
+
+	mux.HandleFunc("GET /taxes", GetAllTaxesHandler)
+	mux.HandleFunc("GET /taxes/", GetAllTaxesHandler)
+
+	mux.HandleFunc("GET /taxes/{id}", GetTaxByIDHandler)
+	mux.HandleFunc("GET /taxes/{id}/", GetTaxByIDHandler)
+
+	mux.HandleFunc("PUT /taxes/{id}", PutTaxHandler)
+	mux.HandleFunc("PUT /taxes/{id}/", PutTaxHandler)
+
+	mux.HandleFunc("DELETE /taxes/{id}", DeleteTaxByIDHandler)
+	mux.HandleFunc("DELETE /taxes/{id}/", DeleteTaxByIDHandler)
+}
+
+func GetAllTaxesHandler(w http.ResponseWriter, r *http.Request) {
+	taxes, err := TaxService.GetAllTaxes()
+	if err != nil {
+		ErrorResponse(w, "Could not retrieve taxes data", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, taxes)
+	slog.InfoContext(r.Context(), "Retrieved all taxes")
+}
+
+func GetTaxByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	tax, err := TaxService.GetTaxByID(id)
+	if errors.Is(err, service.ErrTaxNotRead) {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("ETag", etag(tax.Version))
+	writeJSON(w, r, http.StatusOK, tax)
+	slog.InfoContext(r.Context(), "Retrieved tax", "ID", id)
+}
+
+func PostTaxHandler(w http.ResponseWriter, r *http.Request) {
+	tax, err := parseTax(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if err = TaxService.AddNewTax(r.Context(), tax); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	if tax, err = TaxService.GetTaxByID(tax.ID); err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(tax.Version))
+	writeCreated(w, r, "/taxes/"+url.PathEscape(tax.ID), tax)
+	slog.InfoContext(r.Context(), "Added tax", "ID", tax.ID)
+}
+
+func PutTaxHandler(w http.ResponseWriter, r *http.Request) {
+	tax, err := parseTax(r)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		ServiceError(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	id := r.PathValue("id")
+	if id != tax.ID {
+		ErrorResponse(w, "tax ID does not match id", http.StatusBadRequest)
+		return
+	}
+
+	current, _ := TaxService.GetTaxByID(id)
+	if err = TaxService.ModifyTax(r.Context(), tax, expectedVersion(r, current.Version)); errors.Is(err, service.ErrConflict) {
+		ServiceError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		ServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	if tax, err = TaxService.GetTaxByID(id); err != nil {
+		ServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(tax.Version))
+	writeJSON(w, r, http.StatusOK, tax)
+	slog.InfoContext(r.Context(), "Updated tax", "ID", id)
+}
+
+func DeleteTaxByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	current, _ := TaxService.GetTaxByID(id)
+	if err := TaxService.DeleteTax(r.Context(), id, expectedVersion(r, current.Version)); err != nil {
+		ServiceError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	slog.InfoContext(r.Context(), "Deleted tax", "ID", id)
+}
+
+// parseTax only accepts JSON since tax rates carry lists of products and categories
+func parseTax(r *http.Request) (models.TaxRate, error) {
+	var tax models.TaxRate
+	if r.Header.Get("Content-Type") != "application/json" {
+		return tax, ErrUnsupportedContentType
+	}
+	if err := json.NewDecoder(r.Body).Decode(&tax); err != nil {
+		return tax, errors.New("invalid JSON payload")
+	}
+	return tax, nil
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIVersion is a group of routes served below a common prefix. Versions are served side
+// by side, so a v2 can change the shape of some resources while v1 clients keep working.
+type APIVersion struct {
+	// Prefix is the path prefix of the version, e.g. /v1, or empty for the unversioned routes
+	Prefix    string
+	Endpoints func(mux *Router)
+	// Deprecated and Sunset are set once a version is on its way out: every response then
+	// carries the Deprecation and Sunset headers and a link to the successor
+	Deprecated time.Time
+	Sunset     time.Time
+	Successor  string
+}
+
+// APIVersions are the served versions. The routes at the root are the ones from before
+// versioning; they stay until their sunset so that existing tablets keep working.
+var APIVersions = []APIVersion{
+	{Prefix: "/v1", Endpoints: V1Endpoints},
+	{
+		Prefix:     "",
+		Endpoints:  V1Endpoints,
+		Deprecated: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		Sunset:     time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC),
+		Successor:  "/v1",
+	},
+}
+
+// V1Endpoints registers the routes of version 1
+func V1Endpoints(mux *Router) {
+	InventoryEndpoints(mux)
+	MenuEndpoints(mux)
+	OrderEndpoints(mux)
+	AggregationEndpoints(mux)
+	CustomerEndpoints(mux)
+	LoyaltyEndpoints(mux)
+	PromotionEndpoints(mux)
+	TaxEndpoints(mux)
+	QueueEndpoints(mux)
+	StaffEndpoints(mux)
+	APIKeyEndpoints(mux)
+	AuditEndpoints(mux)
+	MetricsEndpoints(mux)
+	OpenAPIEndpoints(mux)
+}
+
+// VersionEndpoints registers every API version on the router
+func VersionEndpoints(mux *Router) {
+	for _, version := range APIVersions {
+		var middleware func(http.HandlerFunc) http.HandlerFunc
+		if !version.Deprecated.IsZero() {
+			middleware = withDeprecation(version)
+		}
+		version.Endpoints(mux.Group(version.Prefix, middleware))
+	}
+}
+
+// withDeprecation announces the deprecation (RFC 9745) and sunset (RFC 8594) of the version
+// and links the same path in its successor
+func withDeprecation(version APIVersion) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", version.Deprecated.Unix()))
+			if !version.Sunset.IsZero() {
+				w.Header().Set("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if version.Successor != "" {
+				successor := version.Successor + strings.TrimPrefix(r.URL.Path, version.Prefix)
+				w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+			}
+			next(w, r)
+		}
+	}
+}
@@ -8,42 +8,48 @@ import (
 )
 
 var (
-	ErrNotFoundID             = errors.New("id was not found")
+	ErrNotFoundID             = newNotFoundError("id was not found")
 	ErrUnsupportedContentType = errors.New("unsupported content type")
 )
 
 func GetTotalSales() (models.TotalSales, error) {
-	m := NewMenuService()
 	totalSales := models.TotalSales{}
 	ordersStruct := Order{}
+	// refundedNet is the part of the refunds without tax, which is what is taken off the sales
+	refundedNet := 0.0
 
 	err := ordersStruct.LoadOrdersCache()
 	if err != nil {
 		return totalSales, err
 	}
-	err = m.LoadMenuCache()
-	if err != nil {
-		return totalSales, err
-	}
-	if len(ordersStruct.cacheOrders) == 0 {
-		return totalSales, ErrOrderNotRead
-	}
 	for _, order := range ordersStruct.cacheOrders {
-		if order.Status == "Closed" {
+		if order.Status == OrderClosed {
 			for _, product := range order.Items {
 				if err = validateAggregation(product); err != nil {
 					return totalSales, err
 				}
-				menu, errMenu := m.GetMenuByID(product.ProductID)
-				if errMenu != nil {
-					return models.TotalSales{}, err
-				}
-				totalSales.Amount = totalSales.Amount + float64(product.Quantity)*menu.Price
 			}
-		} else if order.Status != "Open" && order.Status != "Closed" {
+			totals, err := orderAmounts(order)
+			if err != nil {
+				return models.TotalSales{}, err
+			}
+			totalSales.GrossSales += totals.Gross
+			totalSales.Discounts += totals.Discount
+			totalSales.Refunds += totals.Refunded
+			refundedNet += totals.Refunded - totals.RefundedTax
+			totalSales.TaxCollected += totals.Tax - totals.RefundedTax
+			totalSales.NetRevenue += totals.Total - totals.Tax - (totals.Refunded - totals.RefundedTax)
+		} else if order.Status != OrderOpen && order.Status != OrderReady && order.Status != OrderClosed {
 			return models.TotalSales{}, errors.New("order is not closed")
 		}
 	}
+	totalSales.GrossSales = roundMoney(totalSales.GrossSales)
+	totalSales.Discounts = roundMoney(totalSales.Discounts)
+	totalSales.Refunds = roundMoney(totalSales.Refunds)
+	totalSales.NetSales = roundMoney(totalSales.GrossSales - totalSales.Discounts - refundedNet)
+	totalSales.TaxCollected = roundMoney(totalSales.TaxCollected)
+	totalSales.NetRevenue = roundMoney(totalSales.NetRevenue)
+	totalSales.Amount = totalSales.NetSales
 	return totalSales, nil
 }
 
@@ -54,13 +60,10 @@ func GetPopularItems() ([]models.PopularItem, error) {
 	if err != nil {
 		return []models.PopularItem{}, err
 	}
-	if len(allOrders) == 0 {
-		return []models.PopularItem{}, ErrOrderNotRead
-	}
 	SumProdID := map[string]int{}
 
 	for _, order := range allOrders {
-		if order.Status == "closed" {
+		if order.Status == OrderClosed {
 			for _, product := range order.Items {
 				if err = validateAggregation(product); err != nil {
 					return []models.PopularItem{}, err
@@ -70,19 +73,209 @@ func GetPopularItems() ([]models.PopularItem, error) {
 				}
 				SumProdID[product.ProductID] = SumProdID[product.ProductID] + product.Quantity
 			}
-		} else if order.Status != "Open" && order.Status != "Closed" {
-			return []models.PopularItem{}, errors.New("order is neither closed nor open")
+		} else if order.Status != OrderOpen && order.Status != OrderReady && order.Status != OrderClosed {
+			return []models.PopularItem{}, errors.New("order is neither closed, ready nor open")
 		}
 	}
 	return GetTopItemsByQuantity(SumProdID, 3), nil
 }
 
+// GetCustomerLifetimeValues reports how many closed orders each customer has and how much they spent,
+// biggest spenders first
+func GetCustomerLifetimeValues() ([]models.CustomerLifetimeValue, error) {
+	customers, err := NewCustomerService().GetAllCustomers()
+	if err != nil {
+		return nil, err
+	}
+	orders, err := NewOrderService().GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]models.CustomerLifetimeValue, len(customers))
+	indexByID := make(map[int]int)
+	for i, customer := range customers {
+		values[i] = models.CustomerLifetimeValue{CustomerID: customer.ID, Name: customer.Name}
+		indexByID[customer.ID] = i
+	}
+	for _, order := range orders {
+		index, exists := indexByID[order.CustomerID]
+		if !exists || order.Status != OrderClosed {
+			continue
+		}
+		totals, err := orderAmounts(order)
+		if err != nil {
+			return nil, err
+		}
+		values[index].TotalSpent = roundMoney(values[index].TotalSpent + totals.Total - totals.Refunded)
+		values[index].OrdersCount++
+		if values[index].FirstOrderAt == "" || order.CreatedAt < values[index].FirstOrderAt {
+			values[index].FirstOrderAt = order.CreatedAt
+		}
+		if order.CreatedAt > values[index].LastOrderAt {
+			values[index].LastOrderAt = order.CreatedAt
+		}
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].TotalSpent > values[j].TotalSpent
+	})
+	return values, nil
+}
+
+// GetPaymentsByMethod sums up the payments taken on all orders per payment method
+func GetPaymentsByMethod() ([]models.PaymentMethodSummary, error) {
+	orders, err := NewOrderService().GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+	summaries := []models.PaymentMethodSummary{}
+	indexByMethod := make(map[string]int)
+	for _, order := range orders {
+		for _, payment := range order.Payments {
+			index, exists := indexByMethod[payment.Method]
+			if !exists {
+				index = len(summaries)
+				indexByMethod[payment.Method] = index
+				summaries = append(summaries, models.PaymentMethodSummary{Method: payment.Method})
+			}
+			summaries[index].Count++
+			summaries[index].Amount = roundMoney(summaries[index].Amount + payment.Amount)
+			summaries[index].Tips = roundMoney(summaries[index].Tips + payment.Tip)
+			summaries[index].Tendered = roundMoney(summaries[index].Tendered + payment.Tendered)
+			summaries[index].Change = roundMoney(summaries[index].Change + payment.Change)
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Method < summaries[j].Method
+	})
+	return summaries, nil
+}
+
+// GetSalesByStaff reports per member of staff how many orders they took and closed and the sales
+// and tips of the orders they closed, biggest sellers first
+func GetSalesByStaff() ([]models.StaffSales, error) {
+	staff, err := NewStaffService().GetAllStaff()
+	if err != nil {
+		return nil, err
+	}
+	orders, err := NewOrderService().GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	sales := make([]models.StaffSales, len(staff))
+	indexByID := make(map[int]int)
+	for i, val := range staff {
+		sales[i] = models.StaffSales{StaffID: val.ID, Name: val.Name}
+		indexByID[val.ID] = i
+	}
+	// Orders made without a staff key, or by staff deleted since, are reported as staff 0
+	indexOf := func(staffID int) int {
+		index, exists := indexByID[staffID]
+		if !exists {
+			index, exists = indexByID[0]
+			if !exists {
+				index = len(sales)
+				indexByID[0] = index
+				sales = append(sales, models.StaffSales{Name: "unattributed"})
+			}
+		}
+		return index
+	}
+	for _, order := range orders {
+		sales[indexOf(order.CreatedBy)].OrdersTaken++
+		if order.Status != OrderClosed {
+			continue
+		}
+		amount, tips, err := closedOrderSales(order)
+		if err != nil {
+			return nil, err
+		}
+		index := indexOf(order.ClosedBy)
+		sales[index].OrdersClosed++
+		sales[index].Sales = roundMoney(sales[index].Sales + amount)
+		sales[index].Tips = roundMoney(sales[index].Tips + tips)
+	}
+
+	sort.SliceStable(sales, func(i, j int) bool {
+		return sales[i].Sales > sales[j].Sales
+	})
+	return sales, nil
+}
+
+// GetSalesByShift reports the orders closed during every shift with their sales and tips,
+// latest shift first
+func GetSalesByShift() ([]models.ShiftSales, error) {
+	s := NewStaffService()
+	staff, err := s.GetAllStaff()
+	if err != nil {
+		return nil, err
+	}
+	shifts, err := s.GetShifts(0)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := NewOrderService().GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int]string)
+	for _, val := range staff {
+		names[val.ID] = val.Name
+	}
+	sales := make([]models.ShiftSales, len(shifts))
+	indexByID := make(map[int]int)
+	for i, shift := range shifts {
+		sales[i] = models.ShiftSales{
+			ShiftID:  shift.ID,
+			StaffID:  shift.StaffID,
+			Name:     names[shift.StaffID],
+			ClockIn:  shift.ClockIn,
+			ClockOut: shift.ClockOut,
+		}
+		indexByID[shift.ID] = i
+	}
+	for _, order := range orders {
+		index, exists := indexByID[order.ClosedShiftID]
+		if !exists || order.Status != OrderClosed {
+			continue
+		}
+		amount, tips, err := closedOrderSales(order)
+		if err != nil {
+			return nil, err
+		}
+		sales[index].OrdersClosed++
+		sales[index].Sales = roundMoney(sales[index].Sales + amount)
+		sales[index].Tips = roundMoney(sales[index].Tips + tips)
+	}
+
+	sort.SliceStable(sales, func(i, j int) bool {
+		return sales[i].ClockIn > sales[j].ClockIn
+	})
+	return sales, nil
+}
+
+// closedOrderSales returns what a closed order brought in after refunds and the tips paid on it
+func closedOrderSales(order models.Order) (float64, float64, error) {
+	totals, err := orderAmounts(order)
+	if err != nil {
+		return 0, 0, err
+	}
+	tips := 0.0
+	for _, payment := range order.Payments {
+		tips += payment.Tip
+	}
+	return totals.Total - totals.Refunded, tips, nil
+}
+
 // Helper function to get top N items by quantity
 func GetTopItemsByQuantity(productQuantities map[string]int, topN int) []models.PopularItem {
 	m := NewMenuService()
 	var quantities []models.OrderItem
 	for id, quantity := range productQuantities {
-		quantities = append(quantities, models.OrderItem{id, quantity})
+		quantities = append(quantities, models.OrderItem{ProductID: id, Quantity: quantity})
 	}
 
 	// Sort products by quantity in descending order
@@ -91,7 +284,7 @@ func GetTopItemsByQuantity(productQuantities map[string]int, topN int) []models.
 	})
 
 	// Get the top N items (or fewer if there are less than N products)
-	var topItems []models.PopularItem
+	topItems := []models.PopularItem{}
 	for i := 0; i < len(quantities) && i < topN; i++ {
 		menu, menuErr := m.GetMenuByID(quantities[i].ProductID)
 		if menuErr != nil {
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+
+	"hot-cofee/models"
+)
+
+func TestAggregationsWithoutOrders(t *testing.T) {
+	useEmptyStorage(t)
+
+	totals, err := GetTotalSales()
+	if err != nil {
+		t.Fatalf("GetTotalSales failed: %v", err)
+	}
+	if totals != (models.TotalSales{}) {
+		t.Fatalf("got %+v, expected zero totals", totals)
+	}
+
+	items, err := GetPopularItems()
+	if err != nil {
+		t.Fatalf("GetPopularItems failed: %v", err)
+	}
+	if items == nil || len(items) != 0 {
+		t.Fatalf("got %#v, expected an empty list", items)
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"hot-cofee/internal/config"
+	"hot-cofee/internal/dal"
+	"hot-cofee/models"
+)
+
+// Roles of API keys, from the least to the most permitted. RoleMetrics stands apart: its keys
+// may only read the metrics, so that a scraper does not need a key that can change data.
+const (
+	RoleBarista = "barista"
+	RoleManager = "manager"
+	RoleAdmin   = "admin"
+	RoleMetrics = "metrics"
+)
+
+// apiKeyPrefix marks the keys of this API so that they are easy to spot in leaked config
+const apiKeyPrefix = "hc_"
+
+var (
+	ErrAPIKeyNotRead  = newError(CodeStorage, "API keys were not read")
+	ErrInvalidAPIKey  = newError(CodeInvalidAPIKey, "missing or invalid API key")
+	roleRanks         = map[string]int{RoleBarista: 1, RoleManager: 2, RoleAdmin: 3}
+	adminKeyPrincipal = models.APIKey{ID: "admin-key", Name: "--admin-key", Role: RoleAdmin}
+	// apiKeysMu serializes issuing and revoking keys from loading to saving them
+	apiKeysMu sync.Mutex
+)
+
+// RoleAllows reports whether a key with the role may call a route that requires the other role.
+// Routes that require the metrics role are also open to managers and admins.
+func RoleAllows(role, required string) bool {
+	if role == RoleMetrics || required == RoleMetrics {
+		return role == required || (required == RoleMetrics && RoleAllows(role, RoleManager))
+	}
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[required]
+}
+
+// AuthenticateAPIKey returns the stored key, without its hash, matching the key sent by a client.
+// The key given with --admin-key authenticates as admin without being stored.
+func AuthenticateAPIKey(key string) (models.APIKey, error) {
+	if key == "" {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	if adminKey := config.GetAdminKey(); adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+		return adminKeyPrincipal, nil
+	}
+	keys, err := dal.NewAPIKeyRepository().ReadAPIKeys()
+	if err != nil {
+		return models.APIKey{}, errors.Join(ErrAPIKeyNotRead, err)
+	}
+	hash := hashAPIKey(key)
+	for _, val := range keys {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(val.Hash)) == 1 {
+			val.Hash = ""
+			return val, nil
+		}
+	}
+	return models.APIKey{}, ErrInvalidAPIKey
+}
+
+// HasAPIKeys reports whether any key can authenticate, so that the server is not started locked
+func HasAPIKeys() (bool, error) {
+	if config.GetAdminKey() != "" {
+		return true, nil
+	}
+	keys, err := dal.NewAPIKeyRepository().ReadAPIKeys()
+	if err != nil {
+		return false, errors.Join(ErrAPIKeyNotRead, err)
+	}
+	return len(keys) > 0, nil
+}
+
+// GetAllAPIKeys retrieves the issued keys without their hashes
+func GetAllAPIKeys() ([]models.APIKey, error) {
+	keys, err := dal.NewAPIKeyRepository().ReadAPIKeys()
+	if err != nil {
+		return nil, errors.Join(ErrAPIKeyNotRead, err)
+	}
+	result := []models.APIKey{}
+	for _, val := range keys {
+		val.Hash = ""
+		result = append(result, val)
+	}
+	return result, nil
+}
+
+// GetAPIKeyByID retrieves an issued key without its hash
+func GetAPIKeyByID(id string) (models.APIKey, error) {
+	keys, err := GetAllAPIKeys()
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	for _, val := range keys {
+		if val.ID == id {
+			return val, nil
+		}
+	}
+	return models.APIKey{}, newNotFoundError("API key with ID %s not found", id)
+}
+
+// IssueAPIKey creates a key with the role. A key issued to a member of staff attributes what
+// is done with it to them and takes their name and role unless others are given.
+// The key is returned once and only its hash is stored.
+func IssueAPIKey(ctx context.Context, name, role string, staffID int) (models.IssuedAPIKey, error) {
+	if staffID != 0 {
+		staff, err := NewStaffService().GetStaffByID(staffID)
+		if err != nil {
+			return models.IssuedAPIKey{}, referenceError("staff_id", err)
+		}
+		if name == "" {
+			name = staff.Name
+		}
+		if role == "" {
+			role = staff.Role
+		}
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.IssuedAPIKey{}, newValidationError("name", "name cannot be empty")
+	}
+	if roleRanks[role] == 0 && role != RoleMetrics {
+		return models.IssuedAPIKey{}, newValidationError("role", "role must be one of %s, %s, %s or %s", RoleBarista, RoleManager, RoleAdmin, RoleMetrics)
+	}
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+	repo := dal.NewAPIKeyRepository()
+	keys, err := repo.ReadAPIKeys()
+	if err != nil {
+		return models.IssuedAPIKey{}, errors.Join(ErrAPIKeyNotRead, err)
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return models.IssuedAPIKey{}, newError(CodeStorage, "failed to generate API key")
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return models.IssuedAPIKey{}, newError(CodeStorage, "failed to generate API key")
+	}
+	key := apiKeyPrefix + secret
+	record := models.APIKey{
+		ID:        id,
+		Name:      name,
+		Role:      role,
+		StaffID:   staffID,
+		Hash:      hashAPIKey(key),
+		CreatedAt: time.Now().Format(time.DateTime),
+	}
+	if err := repo.WriteAPIKeys(append(keys, record)); err != nil {
+		return models.IssuedAPIKey{}, newError(CodeStorage, "failed to save API key")
+	}
+	record.Hash = ""
+	recordChange(ctx, "create", "api_key", record.ID, nil, record)
+	return models.IssuedAPIKey{APIKey: record, Key: key}, nil
+}
+
+// RevokeAPIKey deletes a key, which stops working with the next request
+func RevokeAPIKey(ctx context.Context, id string) error {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+	repo := dal.NewAPIKeyRepository()
+	keys, err := repo.ReadAPIKeys()
+	if err != nil {
+		return errors.Join(ErrAPIKeyNotRead, err)
+	}
+	for i, val := range keys {
+		if val.ID != id {
+			continue
+		}
+		if err := repo.WriteAPIKeys(append(keys[:i], keys[i+1:]...)); err != nil {
+			return newError(CodeStorage, "failed to revoke API key")
+		}
+		val.Hash = ""
+		recordChange(ctx, "delete", "api_key", id, val, nil)
+		return nil
+	}
+	return newNotFoundError("API key with ID %s not found", id)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
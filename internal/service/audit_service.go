@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"hot-cofee/internal/dal"
+	"hot-cofee/models"
+)
+
+var ErrAuditNotRead = newError(CodeStorage, "audit log was not read")
+
+// auditIgnoredFields are computed on every read and would show up as changes of every order
+var auditIgnoredFields = map[string]bool{
+	"estimated_ready_at": true,
+}
+
+var (
+	// auditMu keeps two changes from being appended with the same audit ID
+	auditMu sync.Mutex
+	// lastAuditID is the ID of the last appended entry, read from the log with the first
+	// entry appended. It is guarded by auditMu.
+	lastAuditID     int
+	lastAuditIDRead bool
+)
+
+// auditActorKey is the context key of the actor of the request being served
+type auditActorKey struct{}
+
+// WithAuditActor returns a context carrying the actor of the request, who the audit entries of
+// the changes the service layer makes on its own while serving it are recorded for
+func WithAuditActor(ctx context.Context, actor models.AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+func auditActorFrom(ctx context.Context) models.AuditActor {
+	actor, _ := ctx.Value(auditActorKey{}).(models.AuditActor)
+	return actor
+}
+
+// recordChange appends an audit entry for a change with the actor and request ID of the
+// context, both for the change requested and for those that follow from it, such as the stock
+// deducted when an order is closed. It is called while the lock of the changed data is still
+// held, so that before and after are exactly the states saved. The change is made either way,
+// so failing to record it is only logged.
+func recordChange(ctx context.Context, action, entity, entityID string, before, after any) {
+	entry := models.AuditEntry{
+		RequestID: RequestIDFrom(ctx),
+		Actor:     auditActorFrom(ctx),
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+	}
+	var errs []error
+	if before != nil {
+		var err error
+		entry.Before, err = json.Marshal(before)
+		errs = append(errs, err)
+	}
+	if after != nil {
+		var err error
+		entry.After, err = json.Marshal(after)
+		errs = append(errs, err)
+	}
+	err := errors.Join(errs...)
+	if err == nil {
+		_, err = recordAudit(entry)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record audit entry", "action", action, "entity", entity, "entity_id", entityID, "error", err)
+	}
+}
+
+// recordAudit appends an entry to the audit log with the next ID, the current time and the
+// fields that differ between its before and after states
+func recordAudit(entry models.AuditEntry) (models.AuditEntry, error) {
+	changes, err := auditChanges(entry.Before, entry.After)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	entry.Changes = changes
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	repo := dal.NewAuditRepository()
+	if !lastAuditIDRead {
+		entries, err := repo.ReadAuditEntries()
+		if err != nil {
+			return models.AuditEntry{}, errors.Join(ErrAuditNotRead, err)
+		}
+		if len(entries) > 0 {
+			lastAuditID = entries[len(entries)-1].ID
+		}
+		lastAuditIDRead = true
+	}
+	entry.ID = lastAuditID + 1
+	entry.Timestamp = time.Now().Format(time.DateTime)
+	if err := repo.AppendAuditEntry(entry); err != nil {
+		return models.AuditEntry{}, newError(CodeStorage, "failed to append audit entry")
+	}
+	lastAuditID = entry.ID
+	return entry, nil
+}
+
+// GetAuditEntries returns one page of the entries matching the filter, newest first, together
+// with the total number of matches before pagination
+func GetAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, int, error) {
+	if filter.Limit < 0 {
+		return nil, 0, newValidationError("limit", "limit cannot be negative")
+	} else if filter.Offset < 0 {
+		return nil, 0, newValidationError("offset", "offset cannot be negative")
+	} else if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return nil, 0, newValidationError("from", "from cannot be after to")
+	}
+	entries, err := dal.NewAuditRepository().ReadAuditEntries()
+	if err != nil {
+		return nil, 0, errors.Join(ErrAuditNotRead, err)
+	}
+	result := []models.AuditEntry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if matchesAuditFilter(entries[i], filter) {
+			result = append(result, entries[i])
+		}
+	}
+
+	total := len(result)
+	if filter.Offset >= total {
+		return []models.AuditEntry{}, total, nil
+	}
+	result = result[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(result) {
+		result = result[:filter.Limit]
+	}
+	return result, total, nil
+}
+
+func matchesAuditFilter(entry models.AuditEntry, filter models.AuditFilter) bool {
+	if filter.Entity != "" && entry.Entity != filter.Entity {
+		return false
+	} else if filter.EntityID != "" && entry.EntityID != filter.EntityID {
+		return false
+	} else if filter.Action != "" && entry.Action != filter.Action {
+		return false
+	} else if filter.KeyID != "" && entry.Actor.KeyID != filter.KeyID {
+		return false
+	} else if filter.StaffID != 0 && entry.Actor.StaffID != filter.StaffID {
+		return false
+	} else if filter.RequestID != "" && entry.RequestID != filter.RequestID {
+		return false
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		timestamp, err := time.ParseInLocation(time.DateTime, entry.Timestamp, time.Local)
+		if err != nil {
+			return false
+		}
+		if !filter.From.IsZero() && timestamp.Before(filter.From) {
+			return false
+		}
+		if !filter.To.IsZero() && timestamp.After(filter.To) {
+			return false
+		}
+	}
+	return true
+}
+
+// auditChanges lists the fields, named by their JSON path, whose values differ between the
+// two JSON documents. A missing document counts as empty, so a creation lists every field.
+func auditChanges(before, after json.RawMessage) ([]models.AuditChange, error) {
+	beforeFields := make(map[string]any)
+	afterFields := make(map[string]any)
+	for _, val := range []struct {
+		document json.RawMessage
+		fields   map[string]any
+	}{{before, beforeFields}, {after, afterFields}} {
+		if len(val.document) == 0 {
+			continue
+		}
+		var decoded any
+		if err := json.Unmarshal(val.document, &decoded); err != nil {
+			return nil, fmt.Errorf("unable to read audited state: %w", err)
+		}
+		flattenJSON("", decoded, val.fields)
+	}
+
+	changes := []models.AuditChange{}
+	for field, value := range beforeFields {
+		if other, exists := afterFields[field]; !exists || !reflect.DeepEqual(value, other) {
+			changes = append(changes, models.AuditChange{Field: field, Before: value, After: afterFields[field]})
+		}
+	}
+	for field, value := range afterFields {
+		if _, exists := beforeFields[field]; !exists {
+			changes = append(changes, models.AuditChange{Field: field, After: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+// flattenJSON adds every scalar, empty array and empty object of a decoded JSON value to
+// fields under its path, e.g. items[0].quantity
+func flattenJSON(path string, value any, fields map[string]any) {
+	switch value := value.(type) {
+	case map[string]any:
+		if len(value) == 0 && path != "" {
+			fields[path] = value
+		}
+		for key, val := range value {
+			if path == "" && auditIgnoredFields[key] {
+				continue
+			}
+			field := key
+			if path != "" {
+				field = path + "." + key
+			}
+			flattenJSON(field, val, fields)
+		}
+	case []any:
+		if len(value) == 0 {
+			fields[path] = value
+		}
+		for i, val := range value {
+			flattenJSON(fmt.Sprintf("%s[%d]", path, i), val, fields)
+		}
+	default:
+		fields[path] = value
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"hot-cofee/internal/dal"
+	"hot-cofee/models"
+)
+
+var (
+	ErrCustomerNotRead   = newError(CodeStorage, "customers were not read")
+	ErrCustomerHasOrders = newError(CodeCustomerHasOrders, "customer has orders")
+)
+
+type Customer struct {
+	cacheCustomers   []models.Customer
+	takenIDCustomers map[int]int
+}
+
+// customersMu serializes the changes of customers from loading to saving them. Deleting a
+// customer takes it after ordersMu, so that no order can reference the customer in between;
+// it is never taken before ordersMu.
+var customersMu sync.Mutex
+
+type CustomerService interface {
+	LoadCustomersCache() error
+	GetAllCustomers() ([]models.Customer, error)
+	GetCustomerByID(ID int) (models.Customer, error)
+	AddNewCustomer(ctx context.Context, customer models.Customer) (models.Customer, error)
+	ModifyCustomer(ctx context.Context, customer models.Customer) error
+	DeleteCustomer(ctx context.Context, ID int) error
+}
+
+func NewCustomerService() CustomerService {
+	return &Customer{
+		cacheCustomers:   []models.Customer{},
+		takenIDCustomers: make(map[int]int),
+	}
+}
+
+// LoadCustomersCache loads the customers from the file to the cache
+func (c *Customer) LoadCustomersCache() error {
+	customers, err := dal.NewCustomerRepository().ReadCustomers()
+	if err != nil {
+		return errors.Join(ErrCustomerNotRead, err)
+	}
+	c.cacheCustomers = customers
+	c.takenIDCustomers = make(map[int]int)
+	for i, val := range c.cacheCustomers {
+		if err = validateCustomer(val); err != nil {
+			return errors.Join(ErrConflict, err)
+		}
+		if _, exists := c.takenIDCustomers[val.ID]; exists {
+			return ErrConflict
+		}
+		c.takenIDCustomers[val.ID] = i
+	}
+	return nil
+}
+
+// GetAllCustomers retrieves all customers
+func (c *Customer) GetAllCustomers() ([]models.Customer, error) {
+	err := c.LoadCustomersCache()
+	if err != nil {
+		return nil, err
+	}
+	if c.cacheCustomers == nil {
+		return []models.Customer{}, nil
+	}
+	return c.cacheCustomers, nil
+}
+
+// GetCustomerByID retrieves a single customer by ID
+func (c *Customer) GetCustomerByID(ID int) (models.Customer, error) {
+	err := c.LoadCustomersCache()
+	if err != nil {
+		return models.Customer{}, err
+	}
+	index, exists := c.takenIDCustomers[ID]
+	if !exists || index < 0 || index >= len(c.cacheCustomers) {
+		return models.Customer{}, newNotFoundError("customer with ID %d not found", ID)
+	}
+	return c.cacheCustomers[index], nil
+}
+
+// AddNewCustomer assigns the next free ID to the customer and persists it
+func (c *Customer) AddNewCustomer(ctx context.Context, customer models.Customer) (models.Customer, error) {
+	customersMu.Lock()
+	defer customersMu.Unlock()
+	err := c.LoadCustomersCache()
+	if err != nil {
+		return models.Customer{}, err
+	}
+	// Customer IDs start at 1 so that 0 can mean "no customer" on an order
+	customer.ID = 1
+	for _, val := range c.cacheCustomers {
+		if val.ID >= customer.ID {
+			customer.ID = val.ID + 1
+		}
+	}
+	customer.CreatedAt = time.Now().Format(time.DateTime)
+	if err := validateCustomer(customer); err != nil {
+		return models.Customer{}, err
+	}
+	c.cacheCustomers = append(c.cacheCustomers, customer)
+	if err := dal.NewCustomerRepository().WriteCustomers(c.cacheCustomers); err != nil {
+		return models.Customer{}, newError(CodeStorage, "failed to save customer")
+	}
+	recordChange(ctx, "create", "customer", strconv.Itoa(customer.ID), nil, customer)
+	return customer, nil
+}
+
+// ModifyCustomer replaces the profile of an existing customer
+func (c *Customer) ModifyCustomer(ctx context.Context, customer models.Customer) error {
+	customersMu.Lock()
+	defer customersMu.Unlock()
+	err := c.LoadCustomersCache()
+	if err != nil {
+		return err
+	}
+	index, exists := c.takenIDCustomers[customer.ID]
+	if !exists || index < 0 || index >= len(c.cacheCustomers) {
+		return newNotFoundError("customer with ID %d not found", customer.ID)
+	}
+	customer.CreatedAt = c.cacheCustomers[index].CreatedAt
+	if err := validateCustomer(customer); err != nil {
+		return err
+	}
+	if c.cacheCustomers[index] == customer {
+		return ErrNothingToModify
+	}
+	before := c.cacheCustomers[index]
+	c.cacheCustomers[index] = customer
+	if err := dal.NewCustomerRepository().WriteCustomers(c.cacheCustomers); err != nil {
+		return newError(CodeStorage, "failed to modify customer")
+	}
+	recordChange(ctx, "update", "customer", strconv.Itoa(customer.ID), before, customer)
+	return nil
+}
+
+// DeleteCustomer removes a customer that has no orders attached. Orders are locked while the
+// customer is checked and removed, since new orders look the customer up under ordersMu.
+func (c *Customer) DeleteCustomer(ctx context.Context, ID int) error {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+	customersMu.Lock()
+	defer customersMu.Unlock()
+	err := c.LoadCustomersCache()
+	if err != nil {
+		return err
+	}
+	index, exists := c.takenIDCustomers[ID]
+	if !exists || index < 0 || index >= len(c.cacheCustomers) {
+		return newNotFoundError("customer with ID %d not found", ID)
+	}
+	_, total, err := NewOrderService().GetOrders(models.OrderFilter{CustomerID: ID})
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return fmt.Errorf("%w: %d orders reference customer %d", ErrCustomerHasOrders, total, ID)
+	}
+	before := c.cacheCustomers[index]
+	c.cacheCustomers = append(c.cacheCustomers[:index], c.cacheCustomers[index+1:]...)
+	if err := dal.NewCustomerRepository().WriteCustomers(c.cacheCustomers); err != nil {
+		return err
+	}
+	recordChange(ctx, "delete", "customer", strconv.Itoa(ID), before, nil)
+	return nil
+}
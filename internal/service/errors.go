@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error codes are stable identifiers that clients can branch on; the messages may change
+const (
+	CodeValidation          = "validation_failed"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodeNothingToModify     = "nothing_to_modify"
+	CodeInsufficientStock   = "insufficient_stock"
+	CodeInsufficientBalance = "insufficient_loyalty_balance"
+	CodeOrderClosed         = "order_closed"
+	CodeOrderNotClosed      = "order_not_closed"
+	CodeOrderNotPaid        = "order_not_paid"
+	CodeOrderHasPayments    = "order_has_payments"
+	CodeCustomerHasOrders   = "customer_has_orders"
+	CodeIdempotencyKeyUsed  = "idempotency_key_reused"
+	CodeInvalidAPIKey       = "invalid_api_key"
+	CodePreconditionFailed  = "precondition_failed"
+	CodeStorage             = "storage_error"
+)
+
+// FieldError names one invalid field of a request. Nested fields are written as
+// JSON paths, e.g. items[1].quantity.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error of the service layer with a stable code and, for validation errors,
+// the fields that are invalid. Wrapped with %w or errors.Join it is found with errors.As.
+type Error struct {
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns the code of the first service error in the chain of err,
+// or an empty string for errors from outside the service layer
+func ErrorCode(err error) string {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Code
+	}
+	return ""
+}
+
+// ErrorFields returns the invalid fields reported by the service error in the chain of err
+func ErrorFields(err error) []FieldError {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Fields
+	}
+	return nil
+}
+
+// AnyVersion makes a change regardless of the version of the resource. Resources saved before
+// they were versioned have version 0.
+const AnyVersion = -1
+
+// VersionMismatch reports a change that was made against another version of the resource than
+// the current one. Its code is precondition_failed.
+type VersionMismatch struct {
+	Current int
+}
+
+func (e *VersionMismatch) Error() string {
+	return fmt.Sprintf("resource was modified, current version is %d", e.Current)
+}
+
+func (e *VersionMismatch) Unwrap() error {
+	return &Error{Code: CodePreconditionFailed, Message: e.Error()}
+}
+
+// checkVersion fails with a VersionMismatch unless the expected version is the current one or AnyVersion.
+// It must be called between loading and saving the resource, with the change serialized by the lock
+// of its store, so that two changes expecting the same version cannot both be made.
+func checkVersion(expected, current int) error {
+	if expected != AnyVersion && expected != current {
+		return &VersionMismatch{Current: current}
+	}
+	return nil
+}
+
+func newError(code, format string, args ...any) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// newValidationError reports an invalid field, the message is also the error text
+func newValidationError(field, format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+	return &Error{
+		Code:    CodeValidation,
+		Message: message,
+		Fields:  []FieldError{{Field: field, Message: message}},
+	}
+}
+
+func newNotFoundError(format string, args ...any) error {
+	return newError(CodeNotFound, format, args...)
+}
+
+// referenceError turns a missing resource that a request refers to into a validation error
+// of the referring field; other errors, like unreadable storage, are kept as they are
+func referenceError(field string, err error) error {
+	if ErrorCode(err) != CodeNotFound {
+		return err
+	}
+	return newValidationError(field, "%v", err)
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"hot-cofee/models"
+)
+
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderModified  = "order.modified"
+	EventOrderReady     = "order.ready"
+	EventOrderClosed    = "order.closed"
+	EventOrderCancelled = "order.cancelled"
+)
+
+// eventBufferSize is how many events a slow subscriber may fall behind before
+// further events are dropped for it
+const eventBufferSize = 64
+
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan models.OrderEvent]struct{}
+}
+
+var orderEvents = &eventBroker{subscribers: make(map[chan models.OrderEvent]struct{})}
+
+// SubscribeOrderEvents returns a channel receiving every order event published from now on
+// and a function that must be called to stop receiving them
+func SubscribeOrderEvents() (<-chan models.OrderEvent, func()) {
+	ch := make(chan models.OrderEvent, eventBufferSize)
+	orderEvents.mu.Lock()
+	orderEvents.subscribers[ch] = struct{}{}
+	orderEvents.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			orderEvents.mu.Lock()
+			delete(orderEvents.subscribers, ch)
+			orderEvents.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publishOrderEvent hands the event to every subscriber without ever blocking the caller
+func publishOrderEvent(eventType string, order models.Order) {
+	event := models.OrderEvent{
+		Type:    eventType,
+		OrderID: order.ID,
+		Order:   order,
+		At:      time.Now().Format(time.DateTime),
+	}
+	orderEvents.mu.Lock()
+	defer orderEvents.mu.Unlock()
+	for ch := range orderEvents.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -1,34 +1,36 @@
 package service
 
 import (
-	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"time"
 
 	"hot-cofee/models"
 )
 
 var (
-	ErrNotExists        = errors.New("resource not found")
-	ErrIDNotExist       = errors.New("item with this id does not exists")
-	ErrZeroLengthID     = errors.New("item cant have 0 length id")
-	ErrConflict         = errors.New("item with this ID already exists")
-	ErrInventoryNotRead = errors.New("inventory was not read")
-	ErrMenuNotRead      = errors.New("menu was not read")
-	ErrOrderNotRead     = errors.New("orders were not read")
-	ErrNothingToModify  = errors.New("nothing to modify")
-	ErrMalformedContent = errors.New("malformed content")
-	ErrNotFound         = errors.New("not found")
+	ErrNotExists        = newNotFoundError("resource not found")
+	ErrIDNotExist       = newNotFoundError("item with this id does not exists")
+	ErrZeroLengthID     = newValidationError("id", "item cant have 0 length id")
+	ErrConflict         = newError(CodeConflict, "item with this ID already exists")
+	ErrInventoryNotRead = newError(CodeStorage, "inventory was not read")
+	ErrMenuNotRead      = newError(CodeStorage, "menu was not read")
+	ErrOrderNotRead     = newError(CodeStorage, "orders were not read")
+	ErrNothingToModify  = newError(CodeNothingToModify, "nothing to modify")
+	ErrMalformedContent = newError(CodeValidation, "malformed content")
+	ErrNotFound         = newNotFoundError("not found")
 )
 
 func validatePostInventory(item models.InventoryItem) error {
 	if item.IngredientID == "" {
-		return errors.New("ingredient ID cannot be empty")
+		return newValidationError("ingredient_id", "ingredient ID cannot be empty")
 	} else if item.Quantity < 0 {
-		return errors.New("quantity cannot be negative")
+		return newValidationError("quantity", "quantity cannot be negative")
 	} else if item.Unit == "" {
-		return errors.New("unit cannot be empty")
+		return newValidationError("unit", "unit cannot be empty")
 	} else if item.Name == "" {
-		return errors.New("name cannot be empty")
+		return newValidationError("name", "name cannot be empty")
 	}
 
 	return nil
@@ -36,15 +38,17 @@ func validatePostInventory(item models.InventoryItem) error {
 
 func validatePostMenu(item models.MenuItem) error {
 	if item.ID == "" {
-		return errors.New("product ID cannot be empty")
+		return newValidationError("product_id", "product ID cannot be empty")
 	} else if item.Price <= 0 {
-		return errors.New("price cannot be negative or zero")
+		return newValidationError("price", "price cannot be negative or zero")
 	} else if item.Description == "" {
-		return errors.New("description cannot be empty")
+		return newValidationError("description", "description cannot be empty")
 	} else if item.Name == "" {
-		return errors.New("name cannot be empty")
+		return newValidationError("name", "name cannot be empty")
 	} else if len(item.Ingredients) < 1 {
-		return errors.New("number of ingredients cannot be less than 1")
+		return newValidationError("ingredients", "number of ingredients cannot be less than 1")
+	} else if item.PrepSeconds < 0 {
+		return newValidationError("prep_seconds", "preparation time cannot be negative")
 	}
 	return nil
 }
@@ -53,17 +57,50 @@ func validatePostMenuIngredients(Ingredients []models.MenuItemIngredient) error
 	takenIDMenuInventory := make(map[string]int)
 	for j, val := range Ingredients {
 		if _, exists := takenIDMenuInventory[val.IngredientID]; exists {
-			return errors.New("duplicated ingredient ID")
+			return newValidationError(fmt.Sprintf("ingredients[%d].ingredient_id", j), "duplicated ingredient ID")
 		}
 		takenIDMenuInventory[val.IngredientID] = j
 
 		if val.Quantity < 0 {
-			return fmt.Errorf("item with quantity %v is less than 0", val.Quantity)
+			return newValidationError(fmt.Sprintf("ingredients[%d].quantity", j), "item with quantity %v is less than 0", val.Quantity)
 		}
 	}
 	return nil
 }
 
+// validateActiveIngredients rejects archived inventory items among the ingredients, except
+// those the menu item already had before it was modified
+func validateActiveIngredients(ingredients, current []models.MenuItemIngredient) error {
+	kept := make(map[string]bool)
+	for _, val := range current {
+		kept[val.IngredientID] = true
+	}
+	i := NewInventoryService()
+	for j, val := range ingredients {
+		if kept[val.IngredientID] {
+			continue
+		}
+		item, err := i.GetInventoryByID(val.IngredientID)
+		if err != nil && ErrorCode(err) != CodeNotFound {
+			return err
+		}
+		if err == nil && item.Archived {
+			return newValidationError(fmt.Sprintf("ingredients[%d].ingredient_id", j), "ingredient %s is archived", val.IngredientID)
+		}
+	}
+	return nil
+}
+
+func equalMenuItems(item1, item2 models.MenuItem) bool {
+	return item1.ID == item2.ID &&
+		item1.Name == item2.Name &&
+		item1.Description == item2.Description &&
+		item1.Category == item2.Category &&
+		item1.Price == item2.Price &&
+		item1.PrepSeconds == item2.PrepSeconds &&
+		equalSlices(item1.Ingredients, item2.Ingredients)
+}
+
 func equalSlices[T comparable](slice1, slice2 []T) bool {
 	if len(slice1) != len(slice2) {
 		return false
@@ -77,19 +114,157 @@ func equalSlices[T comparable](slice1, slice2 []T) bool {
 	return true
 }
 
+func validateCustomer(customer models.Customer) error {
+	if customer.ID < 1 {
+		return newValidationError("id", "customer ID must be positive")
+	} else if strings.TrimSpace(customer.Name) == "" {
+		return newValidationError("name", "name cannot be empty")
+	} else if customer.Email != "" && !strings.Contains(customer.Email, "@") {
+		return newValidationError("email", "email is not valid")
+	}
+	return nil
+}
+
+func validateStaff(staff models.Staff) error {
+	if staff.ID < 1 {
+		return newValidationError("id", "staff ID must be positive")
+	} else if strings.TrimSpace(staff.Name) == "" {
+		return newValidationError("name", "name cannot be empty")
+	} else if roleRanks[staff.Role] == 0 {
+		return newValidationError("role", "role must be one of %s, %s or %s", RoleBarista, RoleManager, RoleAdmin)
+	}
+	return nil
+}
+
+func validateLoyaltyProgram(program models.LoyaltyProgram) error {
+	takenIDRule := make(map[string]bool)
+	for i, rule := range program.EarnRules {
+		field := fmt.Sprintf("earn_rules[%d]", i)
+		if rule.ID == "" {
+			return newValidationError(field+".id", "rule ID cannot be empty")
+		} else if takenIDRule[rule.ID] {
+			return newValidationError(field+".id", "duplicated rule ID %s", rule.ID)
+		} else if rule.Type != EarnPointsPerCurrency && rule.Type != EarnStampPerItem {
+			return newValidationError(field+".type", "unknown earn rule type %q", rule.Type)
+		} else if rule.Rate <= 0 {
+			return newValidationError(field+".rate", "rate must be positive")
+		}
+		takenIDRule[rule.ID] = true
+	}
+	takenIDReward := make(map[string]bool)
+	for i, reward := range program.Rewards {
+		field := fmt.Sprintf("rewards[%d]", i)
+		if reward.ID == "" {
+			return newValidationError(field+".id", "reward ID cannot be empty")
+		} else if takenIDReward[reward.ID] {
+			return newValidationError(field+".id", "duplicated reward ID %s", reward.ID)
+		} else if reward.Name == "" {
+			return newValidationError(field+".name", "reward name cannot be empty")
+		} else if reward.Currency != CurrencyPoints && reward.Currency != CurrencyStamps {
+			return newValidationError(field+".currency", "unknown reward currency %q", reward.Currency)
+		} else if reward.Cost <= 0 {
+			return newValidationError(field+".cost", "reward cost must be positive")
+		}
+		switch reward.Type {
+		case RewardFreeItem:
+			if reward.ProductID == "" {
+				return newValidationError(field+".product_id", "reward %s needs a product ID", reward.ID)
+			}
+		case RewardPercentDiscount:
+			if reward.Value <= 0 || reward.Value > 100 {
+				return newValidationError(field+".value", "reward %s percentage must be between 0 and 100", reward.ID)
+			}
+		case RewardFixedDiscount:
+			if reward.Value <= 0 {
+				return newValidationError(field+".value", "reward %s value must be positive", reward.ID)
+			}
+		default:
+			return newValidationError(field+".type", "unknown reward type %q", reward.Type)
+		}
+		takenIDReward[reward.ID] = true
+	}
+	return nil
+}
+
+func validatePromotion(promotion models.Promotion) error {
+	if promotion.ID == "" {
+		return newValidationError("id", "promotion ID cannot be empty")
+	} else if promotion.Name == "" {
+		return newValidationError("name", "name cannot be empty")
+	}
+	switch promotion.Type {
+	case PromotionPercentage:
+		if promotion.Value <= 0 || promotion.Value > 100 {
+			return newValidationError("value", "percentage must be between 0 and 100")
+		}
+	case PromotionFixed:
+		if promotion.Value <= 0 {
+			return newValidationError("value", "fixed discount must be positive")
+		}
+	case PromotionBuyXGetY:
+		if promotion.BuyQuantity < 1 || promotion.GetQuantity < 1 {
+			return newValidationError("buy_quantity", "buy and get quantities must be at least 1")
+		}
+	default:
+		return newValidationError("type", "unknown promotion type %q", promotion.Type)
+	}
+	if (promotion.StartTime == "") != (promotion.EndTime == "") {
+		return newValidationError("end_time", "start and end time must be set together")
+	}
+	timeFields := []string{"start_time", "end_time"}
+	for i, val := range []string{promotion.StartTime, promotion.EndTime} {
+		if _, err := time.Parse("15:04", val); val != "" && err != nil {
+			return newValidationError(timeFields[i], "time %q is not in HH:MM format", val)
+		}
+	}
+	for i, day := range promotion.Days {
+		if !containsString([]string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}, strings.ToLower(day)) {
+			return newValidationError(fmt.Sprintf("days[%d]", i), "unknown day %q", day)
+		}
+	}
+	return nil
+}
+
+func validateTax(tax models.TaxRate) error {
+	if tax.ID == "" {
+		return newValidationError("id", "tax ID cannot be empty")
+	} else if tax.Name == "" {
+		return newValidationError("name", "name cannot be empty")
+	} else if tax.Rate < 0 || tax.Rate > 100 {
+		return newValidationError("rate", "rate must be between 0 and 100")
+	}
+	return nil
+}
+
+func equalOrderItems(items1, items2 []models.OrderItem) bool {
+	if len(items1) != len(items2) {
+		return false
+	}
+	for i := range items1 {
+		if items1[i].ProductID != items2[i].ProductID ||
+			items1[i].Quantity != items2[i].Quantity ||
+			items1[i].UnitPrice != items2[i].UnitPrice ||
+			items1[i].Status != items2[i].Status ||
+			!equalSlices(items1[i].Modifiers, items2[i].Modifiers) {
+			return false
+		}
+	}
+	return true
+}
+
 func validateOrders(Orders []models.Order) error {
 	takenIdOrder := make(map[int]int)
 	for i, val := range Orders {
 		if _, exists := takenIdOrder[val.ID]; exists {
-			return errors.New("duplicated order id")
+			return newValidationError(fmt.Sprintf("[%d].id", i), "duplicated order id")
 		}
 		takenIdOrder[val.ID] = i
 		if _, exists := takenIdOrder[val.ID]; !exists {
-			return fmt.Errorf("item with order ID %d does not exists", val.ID)
+			return newNotFoundError("item with order ID %d does not exists", val.ID)
 		}
-		for _, items := range val.Items {
+		for j, items := range val.Items {
 			if items.Quantity < 1 {
-				return fmt.Errorf("item with quantity %v is less than 1", items.Quantity)
+				return newValidationError(fmt.Sprintf("[%d].items[%d].quantity", i, j), "item with quantity %v is less than 1", items.Quantity)
 			}
 		}
 	}
@@ -100,45 +275,164 @@ func validateOrder(order models.Order) error {
 	varTakenIdOrder := make(map[string]int)
 	m := NewMenuService()
 	if order.ID < 0 {
-		return errors.New("order ID cannot be negative")
+		return newValidationError("id", "order ID cannot be negative")
 	} else if len(order.Items) == 0 {
-		return errors.New("empty order")
+		return newValidationError("items", "empty order")
 	} else if order.CustomerName == "" {
-		return errors.New("customer name cannot be empty")
+		return newValidationError("customer_name", "customer name cannot be empty")
 	} else if order.Items == nil {
-		return errors.New("empty order")
+		return newValidationError("items", "empty order")
+	} else if order.CustomerID < 0 {
+		return newValidationError("customer_id", "customer ID cannot be negative")
+	}
+	if order.CustomerID != 0 {
+		if _, err := NewCustomerService().GetCustomerByID(order.CustomerID); err != nil {
+			return referenceError("customer_id", err)
+		}
 	}
 	for i, item := range order.Items {
+		field := fmt.Sprintf("items[%d]", i)
 		product, err := m.GetMenuByID(item.ProductID)
 		if err != nil {
-			return err
+			return referenceError(field+".product_id", err)
 		}
 		if _, exists := varTakenIdOrder[item.ProductID]; exists {
-			return errors.New("duplicated products in order")
+			return newValidationError(field+".product_id", "duplicated products in order")
 		}
 		varTakenIdOrder[item.ProductID] = i
 		if item.Quantity <= 0 {
-			return fmt.Errorf("item with quantity %v is less than or equal to 0", item.Quantity)
+			return newValidationError(field+".quantity", "item with quantity %v is less than or equal to 0", item.Quantity)
+		}
+		for j, modifier := range item.Modifiers {
+			if strings.TrimSpace(modifier) == "" {
+				return newValidationError(fmt.Sprintf("%s.modifiers[%d]", field, j), "modifier cannot be empty")
+			}
 		}
 		if err := validatePostMenu(product); err != nil {
-			return err
+			return newValidationError(field+".product_id", "product %s is not valid: %v", product.ID, err)
+		}
+	}
+	return nil
+}
+
+// validateActiveProducts rejects archived products among the lines of an order, except
+// the lines the original order already had
+func validateActiveProducts(order models.Order, original *models.Order) error {
+	kept := make(map[string]bool)
+	if original != nil {
+		for _, item := range original.Items {
+			kept[item.ProductID] = true
+		}
+	}
+	m := NewMenuService()
+	for i, item := range order.Items {
+		if kept[item.ProductID] {
+			continue
+		}
+		product, err := m.GetMenuByID(item.ProductID)
+		if err != nil {
+			return referenceError(fmt.Sprintf("items[%d].product_id", i), err)
+		}
+		if product.Archived {
+			return newValidationError(fmt.Sprintf("items[%d].product_id", i), "product %s is archived", item.ProductID)
+		}
+	}
+	return nil
+}
+
+func validateOrderFilter(filter models.OrderFilter) error {
+	switch strings.TrimPrefix(filter.Sort, "-") {
+	case "", "id", "created_at", "customer":
+	default:
+		return newValidationError("sort", "unsupported sort field %q", strings.TrimPrefix(filter.Sort, "-"))
+	}
+	if filter.Limit < 0 {
+		return newValidationError("limit", "limit cannot be negative")
+	}
+	if filter.Offset < 0 {
+		return newValidationError("offset", "offset cannot be negative")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return newValidationError("from", "from cannot be after to")
+	}
+	return nil
+}
+
+func validatePayment(payment models.Payment, due float64) error {
+	if payment.Method != PaymentCash && payment.Method != PaymentCard && payment.Method != PaymentVoucher {
+		return newValidationError("method", "unknown payment method %q (should be cash, card or voucher)", payment.Method)
+	} else if due <= 0 {
+		return newError(CodeConflict, "order is already fully paid")
+	} else if payment.Amount <= 0 {
+		return newValidationError("amount", "payment amount must be positive")
+	} else if roundMoney(payment.Amount) > due {
+		return newValidationError("amount", "payment amount %.2f is more than the %.2f due", payment.Amount, due)
+	} else if payment.Tip < 0 {
+		return newValidationError("tip", "tip cannot be negative")
+	}
+	if payment.Method != PaymentCash && payment.Tendered != 0 {
+		return newValidationError("tendered", "only cash can be tendered")
+	}
+	if payment.Tendered != 0 && payment.Tendered < payment.Amount+payment.Tip {
+		return newValidationError("tendered", "tendered cash does not cover the amount and tip")
+	}
+	return nil
+}
+
+func validateRefund(refund models.Refund, order models.Order) error {
+	if strings.TrimSpace(refund.Reason) == "" {
+		return newValidationError("reason", "refund reason cannot be empty")
+	} else if len(refund.Items) == 0 {
+		return newValidationError("items", "refund has no items")
+	}
+	refundable := make(map[string]int)
+	for _, item := range order.Items {
+		refundable[item.ProductID] += item.Quantity
+	}
+	for _, previous := range order.Refunds {
+		for _, item := range previous.Items {
+			refundable[item.ProductID] -= item.Quantity
+		}
+	}
+	takenIDRefund := make(map[string]bool)
+	for i, item := range refund.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		if takenIDRefund[item.ProductID] {
+			return newValidationError(field+".product_id", "duplicated products in refund")
+		}
+		takenIDRefund[item.ProductID] = true
+		if item.Quantity <= 0 {
+			return newValidationError(field+".quantity", "refund quantity %d of %s must be positive", item.Quantity, item.ProductID)
+		}
+		if item.Quantity > refundable[item.ProductID] {
+			return newValidationError(field+".quantity", "only %d of %s can still be refunded", refundable[item.ProductID], item.ProductID)
 		}
 	}
 	return nil
 }
 
+func validateItemStatus(order models.Order, status string) error {
+	if status != ItemQueued && status != ItemInProgress && status != ItemDone {
+		return newValidationError("status", "wrong item status %q (should be %q, %q or %q)", status, ItemQueued, ItemInProgress, ItemDone)
+	}
+	if order.Status == OrderClosed {
+		return newError(CodeOrderClosed, "order is already closed")
+	}
+	return nil
+}
+
 func validateCloseOrder(order models.Order) error {
 	if order.ID < 0 {
-		return errors.New("order ID cannot be negative")
+		return newValidationError("id", "order ID cannot be negative")
 	}
 	if order.CustomerName == "" {
-		return errors.New("customer name cannot be empty")
+		return newValidationError("customer_name", "customer name cannot be empty")
 	}
 	if order.Items == nil {
-		return errors.New("items cannot be null")
+		return newValidationError("items", "items cannot be null")
 	}
-	if order.Status == "Closed" {
-		return errors.New("order is already closed")
+	if order.Status == OrderClosed {
+		return newError(CodeOrderClosed, "order is already closed")
 	}
 	return nil
 }
@@ -153,7 +447,7 @@ func validateDeductCheckIngredients(productID string, quantity float64) error {
 	for _, ingredient := range item.Ingredients {
 		requiredQuantity := ingredient.Quantity * quantity
 		if err := CheckInventoryAvailability(ingredient.IngredientID, requiredQuantity); err != nil {
-			return fmt.Errorf("not enough %s (required: %.2f)", ingredient.IngredientID, requiredQuantity)
+			return newError(CodeInsufficientStock, "not enough %s (required: %.2f)", ingredient.IngredientID, requiredQuantity)
 		}
 
 	}
@@ -169,35 +463,56 @@ func CheckInventoryAvailability(ingredientID string, requiredQuantity float64) e
 	}
 	// Check if there is enough quantity
 	if item.Quantity < requiredQuantity {
-		return fmt.Errorf("not enough quantity for ingredient %s", ingredientID)
+		return newError(CodeInsufficientStock, "not enough quantity for ingredient %s", ingredientID)
 	}
 	return nil
 }
 
 func validateModifying(modifiedOrder, originalOrder models.Order) error {
 	if modifiedOrder.ID != originalOrder.ID {
-		return errors.New("order with id does not match")
+		return newValidationError("id", "order with id does not match")
 	}
 	if originalOrder.Status != modifiedOrder.Status {
-		return errors.New("modifying status is not permitted")
+		return newValidationError("status", "modifying status is not permitted")
 	}
-	if modifiedOrder.Status != "Open" && modifiedOrder.Status != "Closed" {
-		return errors.New("wrong order status (should be \"Closed\" or \"Open\")")
+	if modifiedOrder.Status != OrderOpen && modifiedOrder.Status != OrderReady && modifiedOrder.Status != OrderClosed {
+		return newValidationError("status", "wrong order status (should be \"Open\", \"Ready\" or \"Closed\")")
 	}
 	if originalOrder.CreatedAt != modifiedOrder.CreatedAt {
-		return errors.New("modifying created time is not permitted")
+		return newValidationError("created_at", "modifying created time is not permitted")
 	}
 	if originalOrder.ID == modifiedOrder.ID &&
+		originalOrder.CustomerID == modifiedOrder.CustomerID &&
 		originalOrder.CustomerName == modifiedOrder.CustomerName &&
-		equalSlices(originalOrder.Items, modifiedOrder.Items) &&
+		originalOrder.PromoCode == modifiedOrder.PromoCode &&
+		sameRedemption(originalOrder, modifiedOrder) &&
+		equalOrderItems(originalOrder.Items, modifiedOrder.Items) &&
 		originalOrder.Status == modifiedOrder.Status &&
 		originalOrder.CreatedAt == modifiedOrder.CreatedAt {
 
 		return ErrNothingToModify
 	}
+	// A closed order has been paid for, its stock deducted and its points earned, and reports
+	// and refunds rely on it; changing it would reprice it behind all of these
+	if originalOrder.Status == OrderClosed {
+		return newError(CodeOrderClosed, "order is closed and cannot be modified")
+	}
 	return nil
 }
 
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func containsString(values []string, value string) bool {
+	for _, val := range values {
+		if val == value {
+			return true
+		}
+	}
+	return false
+}
+
 func validateAggregation(product models.OrderItem) error {
 	m := NewMenuService()
 
@@ -207,10 +522,10 @@ func validateAggregation(product models.OrderItem) error {
 	}
 
 	if item.Price <= 0 {
-		return errors.New("price is <= 0")
+		return newValidationError("price", "price is <= 0")
 	}
 	if product.Quantity <= 0 {
-		return errors.New("quantity is <= 0")
+		return newValidationError("quantity", "quantity is <= 0")
 	}
 	return nil
 }
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"hot-cofee/models"
+)
+
+func TestValidateModifying(t *testing.T) {
+	order := func(status string, quantity int) models.Order {
+		return models.Order{
+			ID:           1,
+			CustomerName: "Ann",
+			Status:       status,
+			CreatedAt:    "2026-10-01 09:00:00",
+			Items:        []models.OrderItem{{ProductID: "latte", Quantity: quantity, UnitPrice: 3.5, Status: ItemDone}},
+		}
+	}
+	renamed := order(OrderClosed, 1)
+	renamed.CustomerName = "Bob"
+
+	tests := []struct {
+		name      string
+		original  models.Order
+		modified  models.Order
+		code      string
+		unchanged bool
+	}{
+		{name: "open order with new items", original: order(OrderOpen, 1), modified: order(OrderOpen, 2)},
+		{name: "ready order with new items", original: order(OrderReady, 1), modified: order(OrderReady, 2)},
+		{name: "closed order unchanged", original: order(OrderClosed, 1), modified: order(OrderClosed, 1), unchanged: true},
+		{name: "closed order with new items", original: order(OrderClosed, 1), modified: order(OrderClosed, 2), code: CodeOrderClosed},
+		{name: "closed order with a new customer name", original: order(OrderClosed, 1), modified: renamed, code: CodeOrderClosed},
+		{name: "status changed", original: order(OrderOpen, 1), modified: order(OrderClosed, 1), code: CodeValidation},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateModifying(test.modified, test.original)
+			switch {
+			case test.unchanged:
+				if !errors.Is(err, ErrNothingToModify) {
+					t.Fatalf("got %v, expected ErrNothingToModify", err)
+				}
+			case test.code == "":
+				if err != nil {
+					t.Fatalf("got %v, expected no error", err)
+				}
+			default:
+				if code := ErrorCode(err); code != test.code {
+					t.Fatalf("got %v with code %q, expected code %q", err, code, test.code)
+				}
+			}
+		})
+	}
+}
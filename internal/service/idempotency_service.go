@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"hot-cofee/internal/config"
+	"hot-cofee/internal/dal"
+	"hot-cofee/models"
+)
+
+var (
+	ErrIdempotencyNotRead   = newError(CodeStorage, "idempotency keys were not read")
+	ErrIdempotencyKeyReused = newError(CodeIdempotencyKeyUsed, "idempotency key was already used for a different request")
+)
+
+// idempotencyKeysMu serializes reading and writing the file of keys, which requests with
+// different keys do at the same time
+var idempotencyKeysMu sync.Mutex
+
+// LookupIdempotencyKey finds the stored response of an earlier request of the principal with
+// the same key. Expired keys are dropped first; a key sent again with a different request is rejected.
+func LookupIdempotencyKey(principal, key, requestHash string) (models.IdempotencyKey, bool, error) {
+	idempotencyKeysMu.Lock()
+	defer idempotencyKeysMu.Unlock()
+	keys, err := loadIdempotencyKeys()
+	if err != nil {
+		return models.IdempotencyKey{}, false, err
+	}
+	for _, val := range keys {
+		if val.Principal != principal || val.Key != key {
+			continue
+		}
+		if val.RequestHash != requestHash {
+			return models.IdempotencyKey{}, false, ErrIdempotencyKeyReused
+		}
+		return val, true, nil
+	}
+	return models.IdempotencyKey{}, false, nil
+}
+
+// SaveIdempotencyKey stores the response of a request for the configured time window
+func SaveIdempotencyKey(record models.IdempotencyKey) error {
+	if record.Key == "" {
+		return newValidationError("Idempotency-Key", "idempotency key cannot be empty")
+	}
+	idempotencyKeysMu.Lock()
+	defer idempotencyKeysMu.Unlock()
+	keys, err := loadIdempotencyKeys()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	record.CreatedAt = now.Format(time.DateTime)
+	record.ExpiresAt = now.Add(config.GetIdempotencyTTL()).Format(time.DateTime)
+	keys = append(keys, record)
+	if err := dal.NewIdempotencyRepository().WriteKeys(keys); err != nil {
+		return newError(CodeStorage, "failed to save idempotency key")
+	}
+	return nil
+}
+
+// loadIdempotencyKeys reads the stored keys and removes the expired ones from the file
+func loadIdempotencyKeys() ([]models.IdempotencyKey, error) {
+	repo := dal.NewIdempotencyRepository()
+	keys, err := repo.ReadKeys()
+	if err != nil {
+		return nil, errors.Join(ErrIdempotencyNotRead, err)
+	}
+	now := time.Now().Format(time.DateTime)
+	valid := []models.IdempotencyKey{}
+	for _, val := range keys {
+		if val.ExpiresAt > now {
+			valid = append(valid, val)
+		}
+	}
+	if len(valid) != len(keys) {
+		if err := repo.WriteKeys(valid); err != nil {
+			return nil, newError(CodeStorage, "failed to remove expired idempotency keys")
+		}
+	}
+	return valid, nil
+}
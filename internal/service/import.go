@@ -0,0 +1,27 @@
+package service
+
+import (
+	"fmt"
+
+	"hot-cofee/models"
+)
+
+var ErrInvalidImportMode = newValidationError("mode", "invalid import mode (should be upsert or replace)")
+
+const (
+	// ImportUpsert adds new items and updates existing ones
+	ImportUpsert = "upsert"
+	// ImportReplace makes the import the complete list, items missing from it are archived
+	ImportReplace = "replace"
+)
+
+func validateImportMode(mode string) error {
+	if mode != ImportUpsert && mode != ImportReplace {
+		return fmt.Errorf("%w: %q", ErrInvalidImportMode, mode)
+	}
+	return nil
+}
+
+func addImportError(result *models.ImportResult, row int, ID string, err error) {
+	result.Errors = append(result.Errors, models.ImportError{Row: row, ID: ID, Error: err.Error()})
+}
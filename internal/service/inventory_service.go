@@ -1,8 +1,11 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"hot-cofee/internal/dal"
 	"hot-cofee/models"
@@ -13,14 +16,22 @@ type Inventory struct {
 	takenIDInventory map[string]int
 }
 
+// inventoryMu serializes the changes of the inventory from loading to saving it. It is taken
+// after ordersMu and menuMu when stock is deducted or restocked for an order, never before them.
+var inventoryMu sync.Mutex
+
 type InventoryService interface {
 	LoadInventoryCache() error
 	GetAllInventory() ([]models.InventoryItem, error)
+	ListInventory(includeArchived bool) ([]models.InventoryItem, error)
 	GetInventoryByID(id string) (models.InventoryItem, error)
-	AddNewInventoryItem(item models.InventoryItem) error
-	DeleteInventoryItem(id string) error
-	ModifyInventoryItem(item models.InventoryItem) error
-	DeductInventoryItem(ID string, quantity float64) error
+	AddNewInventoryItem(ctx context.Context, item models.InventoryItem) error
+	DeleteInventoryItem(ctx context.Context, id string, version int, staffID int) error
+	RestoreInventoryItem(ctx context.Context, id string, version int, staffID int) error
+	ModifyInventoryItem(ctx context.Context, item models.InventoryItem, version int) error
+	DeductInventoryItem(ctx context.Context, ID string, quantity float64, staffID int) error
+	RestockInventoryItem(ctx context.Context, ID string, quantity float64, staffID int) error
+	ImportInventory(ctx context.Context, items []models.InventoryItem, mode string, dryRun bool) (models.ImportResult, error)
 }
 
 func NewInventoryService() InventoryService {
@@ -51,7 +62,7 @@ func (i *Inventory) LoadInventoryCache() error {
 	return nil
 }
 
-// GetAllInventory retrieves all inventory items
+// GetAllInventory retrieves all inventory items including the archived ones
 func (i *Inventory) GetAllInventory() ([]models.InventoryItem, error) {
 	err := i.LoadInventoryCache()
 	if err != nil {
@@ -60,6 +71,21 @@ func (i *Inventory) GetAllInventory() ([]models.InventoryItem, error) {
 	return i.cacheInventory, nil
 }
 
+// ListInventory retrieves the stocked inventory items, with the archived ones only if asked for
+func (i *Inventory) ListInventory(includeArchived bool) ([]models.InventoryItem, error) {
+	inventory, err := i.GetAllInventory()
+	if err != nil {
+		return nil, err
+	}
+	listed := []models.InventoryItem{}
+	for _, item := range inventory {
+		if includeArchived || !item.Archived {
+			listed = append(listed, item)
+		}
+	}
+	return listed, nil
+}
+
 // GetInventoryByID retrieves a single inventory item by ID
 func (i *Inventory) GetInventoryByID(id string) (models.InventoryItem, error) {
 	err := i.LoadInventoryCache()
@@ -68,91 +94,266 @@ func (i *Inventory) GetInventoryByID(id string) (models.InventoryItem, error) {
 	}
 	index, exists := i.takenIDInventory[id]
 	if !exists || index < 0 || index >= len(i.cacheInventory) {
-		return models.InventoryItem{}, fmt.Errorf("item with ingredient ID %s not found", id)
+		return models.InventoryItem{}, newNotFoundError("item with ingredient ID %s not found", id)
 	}
 	return i.cacheInventory[index], nil
 }
 
 // AddNewInventoryItem adds a new inventory item to the cache and persists it
-func (i *Inventory) AddNewInventoryItem(item models.InventoryItem) error {
+func (i *Inventory) AddNewInventoryItem(ctx context.Context, item models.InventoryItem) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
 	err := i.LoadInventoryCache()
 	if err != nil {
 		return err
 	}
-	if _, exists := i.takenIDInventory[item.IngredientID]; exists {
+	if index, exists := i.takenIDInventory[item.IngredientID]; exists && i.cacheInventory[index].Archived {
+		return newError(CodeConflict, "item with ingredient ID %s is archived, restore it instead", item.IngredientID)
+	} else if exists {
 		return ErrConflict
 	}
 	if err := validatePostInventory(item); err != nil {
 		return err
 	}
+	item.Version = 1
+	item.Archived = false
+	item.ArchivedAt = ""
 	i.cacheInventory = append(i.cacheInventory, item)
 	if err := dal.NewInventoryRepository().WriteInventory(i.cacheInventory); err != nil {
-		return errors.New("failed to save inventory item")
+		return newError(CodeStorage, "failed to save inventory item")
 	}
+	recordChange(ctx, "create", "inventory_item", item.IngredientID, nil, item)
 	return nil
 }
 
-// DeleteInventoryItem deletes an inventory item by ID
-func (i *Inventory) DeleteInventoryItem(id string) error {
+// DeleteInventoryItem archives an inventory item. New menu items cannot use it any more, but
+// it stays for the menu items and orders that refer to it, and can be restored.
+func (i *Inventory) DeleteInventoryItem(ctx context.Context, id string, version int, staffID int) error {
+	return i.setInventoryItemArchived(ctx, id, version, staffID, true)
+}
+
+// RestoreInventoryItem brings an archived inventory item back on behalf of the member of staff
+func (i *Inventory) RestoreInventoryItem(ctx context.Context, id string, version int, staffID int) error {
+	return i.setInventoryItemArchived(ctx, id, version, staffID, false)
+}
+
+func (i *Inventory) setInventoryItemArchived(ctx context.Context, id string, version int, staffID int, archived bool) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
 	err := i.LoadInventoryCache()
 	if err != nil {
 		return err
 	}
 	index, exists := i.takenIDInventory[id]
 	if !exists || index < 0 || index >= len(i.cacheInventory) {
-		return fmt.Errorf("item with ingredient ID %s not found", id)
+		return newNotFoundError("item with ingredient ID %s not found", id)
 	}
-	i.cacheInventory = append(i.cacheInventory[:index], i.cacheInventory[index+1:]...)
-	err = dal.NewInventoryRepository().WriteInventory(i.cacheInventory)
-	if err != nil {
+	item := &i.cacheInventory[index]
+	if err := checkVersion(version, item.Version); err != nil {
 		return err
 	}
+	before := *item
+	if item.Archived && archived {
+		return newError(CodeConflict, "item with ingredient ID %s is already archived", id)
+	} else if !item.Archived && !archived {
+		return newError(CodeConflict, "item with ingredient ID %s is not archived", id)
+	}
+	item.Archived = archived
+	item.ArchivedAt = ""
+	if archived {
+		item.ArchivedAt = time.Now().Format(time.DateTime)
+	}
+	item.UpdatedBy = staffID
+	item.Version++
+	if err := dal.NewInventoryRepository().WriteInventory(i.cacheInventory); err != nil {
+		return newError(CodeStorage, "failed to archive inventory item")
+	}
+	action := "restore"
+	if archived {
+		action = "archive"
+	}
+	recordChange(ctx, action, "inventory_item", id, before, *item)
 	return nil
 }
 
 // ModifyInventoryItem modifies an existing inventory item
-func (i *Inventory) ModifyInventoryItem(item models.InventoryItem) error {
+func (i *Inventory) ModifyInventoryItem(ctx context.Context, item models.InventoryItem, version int) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
 	err := i.LoadInventoryCache()
 	if err != nil {
 		return err
 	}
 	index, exists := i.takenIDInventory[item.IngredientID]
 	if !exists || index < 0 || index >= len(i.cacheInventory) {
-		return fmt.Errorf("item with ingredient ID %s not found", item.IngredientID)
+		return newNotFoundError("item with ingredient ID %s not found", item.IngredientID)
+	}
+	if err := checkVersion(version, i.cacheInventory[index].Version); err != nil {
+		return err
+	}
+	if i.cacheInventory[index].Archived {
+		return newError(CodeConflict, "item with ingredient ID %s is archived, restore it first", item.IngredientID)
 	}
 	if err := validatePostInventory(item); err != nil {
 		return err
 	}
-	if i.cacheInventory[index] == item {
+	item.Archived = false
+	item.ArchivedAt = ""
+	item.Version = i.cacheInventory[index].Version
+	if sameInventoryItem(i.cacheInventory[index], item) {
 		return ErrNothingToModify
 	}
+	before := i.cacheInventory[index]
+	item.Version++
 	i.cacheInventory[index] = item
 	err = dal.NewInventoryRepository().WriteInventory(i.cacheInventory)
 	if err != nil {
 		return err
 	}
+	recordChange(ctx, "update", "inventory_item", item.IngredientID, before, item)
 	return nil
 }
 
-// DeductInventoryItem deducts a certain quantity from the inventory item
-func (i *Inventory) DeductInventoryItem(ID string, quantity float64) error {
+// DeductInventoryItem deducts a certain quantity from the inventory item on behalf of the member of staff
+func (i *Inventory) DeductInventoryItem(ctx context.Context, ID string, quantity float64, staffID int) error {
+	if quantity < 0 {
+		return newValidationError("quantity", "deduct quantity cannot be negative")
+	}
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
 	err := i.LoadInventoryCache()
 	if err != nil {
 		return err
 	}
-	index, _ := i.takenIDInventory[ID]
-	item, err := i.GetInventoryByID(ID)
-	if err != nil {
-		return err
+	index, exists := i.takenIDInventory[ID]
+	if !exists || index < 0 || index >= len(i.cacheInventory) {
+		return newNotFoundError("item with ingredient ID %s not found", ID)
 	}
+	item := i.cacheInventory[index]
 	i.cacheInventory[index].Quantity = item.Quantity - quantity
 	if err := validatePostInventory(i.cacheInventory[index]); err != nil {
 		i.cacheInventory[index].Quantity = item.Quantity
-		return errors.New("not enough quantity")
+		return newError(CodeInsufficientStock, "not enough quantity")
+	}
+	i.cacheInventory[index].UpdatedBy = staffID
+	i.cacheInventory[index].Version++
+	err = dal.NewInventoryRepository().WriteInventory(i.cacheInventory)
+	if err != nil {
+		return err
+	}
+	recordChange(ctx, "deduct", "inventory_item", ID, item, i.cacheInventory[index])
+	return nil
+}
+
+// RestockInventoryItem puts a certain quantity back into the inventory item on behalf of the member of staff
+func (i *Inventory) RestockInventoryItem(ctx context.Context, ID string, quantity float64, staffID int) error {
+	if quantity < 0 {
+		return newValidationError("quantity", "restock quantity cannot be negative")
+	}
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	err := i.LoadInventoryCache()
+	if err != nil {
+		return err
+	}
+	index, exists := i.takenIDInventory[ID]
+	if !exists || index < 0 || index >= len(i.cacheInventory) {
+		return newNotFoundError("item with ingredient ID %s not found", ID)
 	}
+	before := i.cacheInventory[index]
+	i.cacheInventory[index].Quantity += quantity
+	i.cacheInventory[index].UpdatedBy = staffID
+	i.cacheInventory[index].Version++
 	err = dal.NewInventoryRepository().WriteInventory(i.cacheInventory)
 	if err != nil {
 		return err
 	}
+	recordChange(ctx, "restock", "inventory_item", ID, before, i.cacheInventory[index])
 	return nil
 }
+
+// ImportInventory checks every imported item and saves them all, or none if any row has an error.
+// A dry run only reports what would change.
+func (i *Inventory) ImportInventory(ctx context.Context, items []models.InventoryItem, mode string, dryRun bool) (models.ImportResult, error) {
+	if err := validateImportMode(mode); err != nil {
+		return models.ImportResult{}, err
+	}
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	err := i.LoadInventoryCache()
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+	result := models.ImportResult{Mode: mode, DryRun: dryRun}
+	inventory := append([]models.InventoryItem(nil), i.cacheInventory...)
+	if mode == ImportReplace {
+		inventory = nil
+	}
+	rows := make(map[string]int)
+	for j, item := range items {
+		row := j + 1
+		if first, exists := rows[item.IngredientID]; exists {
+			addImportError(&result, row, item.IngredientID, fmt.Errorf("ingredient ID is already imported in row %d", first))
+			continue
+		}
+		rows[item.IngredientID] = row
+		if err := validatePostInventory(item); err != nil {
+			addImportError(&result, row, item.IngredientID, err)
+			continue
+		}
+
+		// Importing an archived item restores it
+		item.Archived = false
+		item.ArchivedAt = ""
+		index, exists := i.takenIDInventory[item.IngredientID]
+		if exists {
+			item.Version = i.cacheInventory[index].Version
+		}
+		if !exists {
+			item.Version = 1
+			result.Created++
+		} else if sameInventoryItem(i.cacheInventory[index], item) {
+			item = i.cacheInventory[index]
+			result.Unchanged++
+		} else {
+			item.Version++
+			result.Updated++
+		}
+		if exists && mode == ImportUpsert {
+			inventory[index] = item
+		} else {
+			inventory = append(inventory, item)
+		}
+	}
+	if mode == ImportReplace {
+		archivedAt := time.Now().Format(time.DateTime)
+		for _, item := range i.cacheInventory {
+			if _, exists := rows[item.IngredientID]; exists {
+				continue
+			}
+			if !item.Archived {
+				item.Archived = true
+				item.ArchivedAt = archivedAt
+				item.Version++
+				result.Deleted++
+			}
+			inventory = append(inventory, item)
+		}
+	}
+
+	if len(result.Errors) > 0 || dryRun {
+		return result, nil
+	}
+	if err := dal.NewInventoryRepository().WriteInventory(inventory); err != nil {
+		return models.ImportResult{}, newError(CodeStorage, "failed to save imported inventory items")
+	}
+	recordChange(ctx, "import", "inventory_item", "", nil, result)
+	i.cacheInventory = inventory
+	return result, nil
+}
+
+// sameInventoryItem compares two versions of an item regardless of who saved them
+func sameInventoryItem(a, b models.InventoryItem) bool {
+	a.UpdatedBy = b.UpdatedBy
+	return a == b
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+)
+
+// requestIDKey is the context key of the ID of the request being served
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the request it belongs to
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID carried by the context, "" outside of a request
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// LogHandler adds the request ID of the context to every record logged with one, e.g. through
+// slog.InfoContext, so that the logs of the handlers and the service layer can be tied to the request
+type LogHandler struct {
+	slog.Handler
+}
+
+func NewLogHandler(handler slog.Handler) *LogHandler {
+	return &LogHandler{Handler: handler}
+}
+
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIDFrom(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewLogHandler(h.Handler.WithAttrs(attrs))
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return NewLogHandler(h.Handler.WithGroup(name))
+}
@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"hot-cofee/internal/dal"
+	"hot-cofee/models"
+)
+
+var (
+	ErrLoyaltyNotRead      = newError(CodeStorage, "loyalty data was not read")
+	ErrInsufficientBalance = newError(CodeInsufficientBalance, "not enough loyalty balance")
+)
+
+const (
+	EarnPointsPerCurrency = "points_per_currency"
+	EarnStampPerItem      = "stamp_per_item"
+
+	RewardFreeItem        = "free_item"
+	RewardPercentDiscount = "percent_discount"
+	RewardFixedDiscount   = "fixed_discount"
+
+	CurrencyPoints = "points"
+	CurrencyStamps = "stamps"
+)
+
+// loyaltyProgramMu serializes the changes of the loyalty program from loading to saving it.
+// Transactions are only appended for orders, under ordersMu.
+var loyaltyProgramMu sync.Mutex
+
+type Loyalty struct {
+	program           models.LoyaltyProgram
+	cacheTransactions []models.LoyaltyTransaction
+}
+
+type LoyaltyService interface {
+	LoadLoyaltyCache() error
+	GetProgram() (models.LoyaltyProgram, error)
+	SetProgram(ctx context.Context, program models.LoyaltyProgram) error
+	GetBalance(customerID int) (models.LoyaltyBalance, error)
+	GetTransactions(customerID int) ([]models.LoyaltyTransaction, error)
+	ApplyRewards(order *models.Order) error
+	RedeemForOrder(ctx context.Context, order *models.Order) error
+	ReverseRedemption(ctx context.Context, order models.Order) error
+	ReplaceRedemption(ctx context.Context, original models.Order, order *models.Order) (func() error, error)
+	EarnForOrder(ctx context.Context, order models.Order) error
+}
+
+func NewLoyaltyService() LoyaltyService {
+	return &Loyalty{
+		cacheTransactions: []models.LoyaltyTransaction{},
+	}
+}
+
+// LoadLoyaltyCache loads the program and all transactions from the files to the cache
+func (l *Loyalty) LoadLoyaltyCache() error {
+	repo := dal.NewLoyaltyRepository()
+	program, err := repo.ReadProgram()
+	if err != nil {
+		return errors.Join(ErrLoyaltyNotRead, err)
+	}
+	transactions, err := repo.ReadTransactions()
+	if err != nil {
+		return errors.Join(ErrLoyaltyNotRead, err)
+	}
+	l.program = program
+	l.cacheTransactions = transactions
+	return nil
+}
+
+// GetProgram retrieves the configured earn rules and rewards
+func (l *Loyalty) GetProgram() (models.LoyaltyProgram, error) {
+	if err := l.LoadLoyaltyCache(); err != nil {
+		return models.LoyaltyProgram{}, err
+	}
+	if l.program.EarnRules == nil {
+		l.program.EarnRules = []models.EarnRule{}
+	}
+	if l.program.Rewards == nil {
+		l.program.Rewards = []models.Reward{}
+	}
+	return l.program, nil
+}
+
+// SetProgram validates and replaces the earn rules and rewards
+func (l *Loyalty) SetProgram(ctx context.Context, program models.LoyaltyProgram) error {
+	if err := validateLoyaltyProgram(program); err != nil {
+		return err
+	}
+	loyaltyProgramMu.Lock()
+	defer loyaltyProgramMu.Unlock()
+	before, err := l.GetProgram()
+	if err != nil {
+		return err
+	}
+	if err := dal.NewLoyaltyRepository().WriteProgram(program); err != nil {
+		return newError(CodeStorage, "failed to save loyalty program")
+	}
+	recordChange(ctx, "update", "loyalty_program", "", before, program)
+	return nil
+}
+
+// GetBalance sums up all transactions of the customer
+func (l *Loyalty) GetBalance(customerID int) (models.LoyaltyBalance, error) {
+	transactions, err := l.GetTransactions(customerID)
+	if err != nil {
+		return models.LoyaltyBalance{}, err
+	}
+	balance := models.LoyaltyBalance{CustomerID: customerID}
+	for _, transaction := range transactions {
+		balance.Points += transaction.Points
+		balance.Stamps += transaction.Stamps
+	}
+	return balance, nil
+}
+
+// GetTransactions retrieves the transaction history of the customer, oldest first
+func (l *Loyalty) GetTransactions(customerID int) ([]models.LoyaltyTransaction, error) {
+	if _, err := NewCustomerService().GetCustomerByID(customerID); err != nil {
+		return nil, err
+	}
+	if err := l.LoadLoyaltyCache(); err != nil {
+		return nil, err
+	}
+	transactions := []models.LoyaltyTransaction{}
+	for _, transaction := range l.cacheTransactions {
+		if transaction.CustomerID == customerID {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, nil
+}
+
+// ApplyRewards computes the discount of every reward requested on the order against its
+// priced lines and adds them to the order discount
+func (l *Loyalty) ApplyRewards(order *models.Order) error {
+	if len(order.Rewards) == 0 {
+		return nil
+	}
+	if order.CustomerID == 0 {
+		return newValidationError("customer_id", "rewards can only be applied to orders of a known customer")
+	}
+	if err := l.LoadLoyaltyCache(); err != nil {
+		return err
+	}
+	taken := make(map[string]bool)
+	for i, applied := range order.Rewards {
+		reward, err := l.findReward(applied.RewardID)
+		if err != nil {
+			return err
+		}
+		if taken[reward.ID] {
+			return newValidationError(fmt.Sprintf("rewards[%d].reward_id", i), "duplicated reward in order")
+		}
+		taken[reward.ID] = true
+
+		discount := 0.0
+		switch reward.Type {
+		case RewardFreeItem:
+			inOrder := false
+			for _, item := range order.Items {
+				if item.ProductID == reward.ProductID {
+					inOrder = true
+					discount = item.UnitPrice
+				}
+			}
+			if !inOrder {
+				return newValidationError(fmt.Sprintf("rewards[%d].reward_id", i), "reward %s requires product %s in the order", reward.ID, reward.ProductID)
+			}
+		case RewardPercentDiscount:
+			discount = order.Subtotal * reward.Value / 100
+		case RewardFixedDiscount:
+			discount = reward.Value
+		}
+		discount = math.Min(roundMoney(discount), roundMoney(order.Subtotal-order.Discount))
+		order.Rewards[i].Discount = discount
+		order.Discount = roundMoney(order.Discount + discount)
+	}
+	return nil
+}
+
+// RedeemForOrder charges the customer for the rewards applied to a new order and records
+// the paying transaction on every reward
+func (l *Loyalty) RedeemForOrder(ctx context.Context, order *models.Order) error {
+	if len(order.Rewards) == 0 {
+		return nil
+	}
+	balance, err := l.GetBalance(order.CustomerID)
+	if err != nil {
+		return err
+	}
+	transactions, err := l.redemptions(*order, balance)
+	if err != nil {
+		return err
+	}
+	if err := l.appendTransactions(ctx, transactions); err != nil {
+		return err
+	}
+	for i := range order.Rewards {
+		order.Rewards[i].TransactionID = transactions[i].ID
+	}
+	return nil
+}
+
+// ReverseRedemption gives back what was charged for the rewards of an order that is cancelled
+func (l *Loyalty) ReverseRedemption(ctx context.Context, order models.Order) error {
+	if order.CustomerID == 0 || len(order.Rewards) == 0 {
+		return nil
+	}
+	if err := l.LoadLoyaltyCache(); err != nil {
+		return err
+	}
+	return l.appendTransactions(ctx, l.reversals(order))
+}
+
+// ReplaceRedemption charges the modified order's rewards instead of the original's when the
+// customer or the rewards changed. The reversal and the new redemption are saved together, and
+// the returned function takes both back if the order cannot be saved after all.
+func (l *Loyalty) ReplaceRedemption(ctx context.Context, original models.Order, order *models.Order) (func() error, error) {
+	undo := func() error { return nil }
+	if sameRedemption(original, *order) {
+		for i := range order.Rewards {
+			order.Rewards[i].TransactionID = original.Rewards[i].TransactionID
+		}
+		return undo, nil
+	}
+	if err := l.LoadLoyaltyCache(); err != nil {
+		return undo, err
+	}
+	transactions := l.reversals(original)
+	var redeemed []models.LoyaltyTransaction
+	if order.CustomerID != 0 && len(order.Rewards) > 0 {
+		balance, err := l.GetBalance(order.CustomerID)
+		if err != nil {
+			return undo, err
+		}
+		// What the original order gives back is available to the new rewards
+		for _, transaction := range transactions {
+			if transaction.CustomerID == order.CustomerID {
+				balance.Points += transaction.Points
+				balance.Stamps += transaction.Stamps
+			}
+		}
+		if redeemed, err = l.redemptions(*order, balance); err != nil {
+			return undo, err
+		}
+	}
+	transactions = append(transactions, redeemed...)
+	if err := l.appendTransactions(ctx, transactions); err != nil {
+		return undo, err
+	}
+	for i := range order.Rewards {
+		order.Rewards[i].TransactionID = 0
+		if i < len(redeemed) {
+			order.Rewards[i].TransactionID = transactions[len(transactions)-len(redeemed)+i].ID
+		}
+	}
+	return func() error {
+		var undone []models.LoyaltyTransaction
+		for _, transaction := range transactions {
+			undone = append(undone, reversalOf(transaction))
+		}
+		return l.appendTransactions(ctx, undone)
+	}, nil
+}
+
+// sameRedemption tells whether an order modification leaves what was redeemed as it was
+func sameRedemption(original, order models.Order) bool {
+	if original.CustomerID != order.CustomerID || len(original.Rewards) != len(order.Rewards) {
+		return false
+	}
+	for i := range original.Rewards {
+		if original.Rewards[i].RewardID != order.Rewards[i].RewardID {
+			return false
+		}
+	}
+	return true
+}
+
+// redemptions builds the transactions charging the rewards of the order, checking them against the balance
+func (l *Loyalty) redemptions(order models.Order, balance models.LoyaltyBalance) ([]models.LoyaltyTransaction, error) {
+	var transactions []models.LoyaltyTransaction
+	for _, applied := range order.Rewards {
+		reward, err := l.findReward(applied.RewardID)
+		if err != nil {
+			return nil, err
+		}
+		transaction := l.newTransaction(order, "redeem")
+		transaction.RewardID = reward.ID
+		if reward.Currency == CurrencyStamps {
+			transaction.Stamps = -reward.Cost
+			balance.Stamps -= reward.Cost
+		} else {
+			transaction.Points = -reward.Cost
+			balance.Points -= reward.Cost
+		}
+		if balance.Points < 0 || balance.Stamps < 0 {
+			return nil, fmt.Errorf("%w for reward %s", ErrInsufficientBalance, reward.ID)
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// reversals builds the transactions giving back the redemptions of the order that are not
+// reversed yet. Orders saved before rewards kept their transaction are matched by order ID,
+// customer and reward, among the transactions made since the order was created.
+func (l *Loyalty) reversals(order models.Order) []models.LoyaltyTransaction {
+	var transactions []models.LoyaltyTransaction
+	for _, applied := range order.Rewards {
+		for _, redeemed := range l.cacheTransactions {
+			if redeemed.Type != "redeem" || l.reversed(redeemed.ID) {
+				continue
+			}
+			if applied.TransactionID != 0 && redeemed.ID != applied.TransactionID {
+				continue
+			}
+			if applied.TransactionID == 0 && (redeemed.OrderID != order.ID || redeemed.CustomerID != order.CustomerID ||
+				redeemed.RewardID != applied.RewardID || redeemed.CreatedAt < order.CreatedAt) {
+				continue
+			}
+			transactions = append(transactions, reversalOf(redeemed))
+			break
+		}
+	}
+	return transactions
+}
+
+// reversed tells whether the transaction was given back by a reversal that is itself still in force
+func (l *Loyalty) reversed(ID int) bool {
+	for _, transaction := range l.cacheTransactions {
+		if transaction.ReversesID == ID && !l.reversed(transaction.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+func reversalOf(transaction models.LoyaltyTransaction) models.LoyaltyTransaction {
+	return models.LoyaltyTransaction{
+		CustomerID: transaction.CustomerID,
+		OrderID:    transaction.OrderID,
+		Type:       "reverse",
+		Points:     -transaction.Points,
+		Stamps:     -transaction.Stamps,
+		RewardID:   transaction.RewardID,
+		ReversesID: transaction.ID,
+		CreatedAt:  time.Now().Format(time.DateTime),
+	}
+}
+
+// EarnForOrder credits the customer of a closed order according to the active earn rules
+func (l *Loyalty) EarnForOrder(ctx context.Context, order models.Order) error {
+	if order.CustomerID == 0 {
+		return nil
+	}
+	if err := l.LoadLoyaltyCache(); err != nil {
+		return err
+	}
+	totals, err := orderAmounts(order)
+	if err != nil {
+		return err
+	}
+	var transactions []models.LoyaltyTransaction
+	for _, rule := range l.program.EarnRules {
+		if !rule.Active {
+			continue
+		}
+		transaction := l.newTransaction(order, "earn")
+		transaction.RuleID = rule.ID
+		switch rule.Type {
+		case EarnPointsPerCurrency:
+			transaction.Points = int(math.Floor((totals.Gross - totals.Discount) * rule.Rate))
+		case EarnStampPerItem:
+			qualifying := 0
+			for _, item := range order.Items {
+				if len(rule.ProductIDs) == 0 || containsString(rule.ProductIDs, item.ProductID) {
+					qualifying += item.Quantity
+				}
+			}
+			transaction.Stamps = int(math.Floor(float64(qualifying) * rule.Rate))
+		}
+		if transaction.Points != 0 || transaction.Stamps != 0 {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return l.appendTransactions(ctx, transactions)
+}
+
+func (l *Loyalty) findReward(ID string) (models.Reward, error) {
+	for _, reward := range l.program.Rewards {
+		if reward.ID == ID {
+			if !reward.Active {
+				return models.Reward{}, newValidationError("rewards", "reward %s is not active", ID)
+			}
+			return reward, nil
+		}
+	}
+	return models.Reward{}, newValidationError("rewards", "reward %s not found", ID)
+}
+
+func (l *Loyalty) newTransaction(order models.Order, transactionType string) models.LoyaltyTransaction {
+	return models.LoyaltyTransaction{
+		CustomerID: order.CustomerID,
+		OrderID:    order.ID,
+		Type:       transactionType,
+		CreatedAt:  time.Now().Format(time.DateTime),
+	}
+}
+
+// appendTransactions saves the transactions with the next IDs and records them in the audit log
+func (l *Loyalty) appendTransactions(ctx context.Context, transactions []models.LoyaltyTransaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+	if err := l.LoadLoyaltyCache(); err != nil {
+		return err
+	}
+	nextID := 1
+	if len(l.cacheTransactions) > 0 {
+		nextID = l.cacheTransactions[len(l.cacheTransactions)-1].ID + 1
+	}
+	for i := range transactions {
+		transactions[i].ID = nextID
+		nextID++
+		l.cacheTransactions = append(l.cacheTransactions, transactions[i])
+	}
+	if err := dal.NewLoyaltyRepository().WriteTransactions(l.cacheTransactions); err != nil {
+		return newError(CodeStorage, "failed to save loyalty transactions")
+	}
+	for _, transaction := range transactions {
+		recordChange(ctx, transaction.Type, "loyalty_transaction", strconv.Itoa(transaction.ID), nil, transaction)
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"hot-cofee/internal/dal"
 	"hot-cofee/models"
@@ -13,14 +16,21 @@ type Menu struct {
 	takenIDMenu map[string]int
 }
 
+// menuMu serializes the changes of the menu from loading to saving it
+var menuMu sync.Mutex
+
 type MenuService interface {
 	LoadMenuCache() error
 	GetAllMenu() ([]models.MenuItem, error)
+	ListMenu(includeArchived bool) ([]models.MenuItem, error)
 	GetMenuByID(id string) (models.MenuItem, error)
-	DeleteMenuItem(id string) error
-	AddNewMenuItem(item models.MenuItem) error
-	ModifyMenuItem(item models.MenuItem) error
-	DeductMenuProduct(ID string, quantity float64) error
+	DeleteMenuItem(ctx context.Context, id string, version int) error
+	RestoreMenuItem(ctx context.Context, id string, version int) error
+	AddNewMenuItem(ctx context.Context, item models.MenuItem) error
+	ModifyMenuItem(ctx context.Context, item models.MenuItem, version int) error
+	DeductMenuProduct(ctx context.Context, ID string, quantity float64, staffID int) error
+	RestockMenuProduct(ctx context.Context, ID string, quantity float64, staffID int) error
+	ImportMenu(ctx context.Context, items []models.MenuItem, mode string, dryRun bool) (models.ImportResult, error)
 }
 
 func NewMenuService() MenuService {
@@ -56,6 +66,7 @@ func (m *Menu) LoadMenuCache() error {
 	return nil
 }
 
+// GetAllMenu retrieves every menu item including the archived ones, which old orders still refer to
 func (m *Menu) GetAllMenu() ([]models.MenuItem, error) {
 	err := m.LoadMenuCache()
 	if err != nil {
@@ -64,6 +75,21 @@ func (m *Menu) GetAllMenu() ([]models.MenuItem, error) {
 	return m.cacheMenu, nil
 }
 
+// ListMenu retrieves the menu as guests see it, with the archived items only if asked for
+func (m *Menu) ListMenu(includeArchived bool) ([]models.MenuItem, error) {
+	menu, err := m.GetAllMenu()
+	if err != nil {
+		return nil, err
+	}
+	listed := []models.MenuItem{}
+	for _, item := range menu {
+		if includeArchived || !item.Archived {
+			listed = append(listed, item)
+		}
+	}
+	return listed, nil
+}
+
 func (m *Menu) GetMenuByID(id string) (models.MenuItem, error) {
 	err := m.LoadMenuCache()
 	if err != nil {
@@ -72,13 +98,26 @@ func (m *Menu) GetMenuByID(id string) (models.MenuItem, error) {
 	index, exists := m.takenIDMenu[id]
 	// Check if the item exists in the cacheInventory using the id index
 	if !exists || index < 0 || index >= len(m.cacheMenu) {
-		return models.MenuItem{}, fmt.Errorf("item with product ID %s not found", id)
+		return models.MenuItem{}, newNotFoundError("item with product ID %s not found", id)
 	}
 
 	return m.cacheMenu[index], nil
 }
 
-func (m *Menu) DeleteMenuItem(id string) error {
+// DeleteMenuItem archives a menu item. It can no longer be ordered but stays for the orders
+// and reports that refer to it, and can be restored.
+func (m *Menu) DeleteMenuItem(ctx context.Context, id string, version int) error {
+	return m.setMenuItemArchived(ctx, id, version, true)
+}
+
+// RestoreMenuItem brings an archived menu item back to the menu
+func (m *Menu) RestoreMenuItem(ctx context.Context, id string, version int) error {
+	return m.setMenuItemArchived(ctx, id, version, false)
+}
+
+func (m *Menu) setMenuItemArchived(ctx context.Context, id string, version int, archived bool) error {
+	menuMu.Lock()
+	defer menuMu.Unlock()
 	err := m.LoadMenuCache()
 	if err != nil {
 		return err
@@ -86,25 +125,46 @@ func (m *Menu) DeleteMenuItem(id string) error {
 	index, exists := m.takenIDMenu[id]
 	// Check if the item exists in the cacheInventory using the id index
 	if !exists || index < 0 || index >= len(m.cacheMenu) {
-		return fmt.Errorf("item with product ID %s not found", id)
+		return newNotFoundError("item with product ID %s not found", id)
 	}
-	// Remove the item from the cacheInventory slice
-	m.cacheMenu = append(m.cacheMenu[:index], m.cacheMenu[index+1:]...)
-
-	err = dal.NewMenuRepository().WriteMenu(m.cacheMenu)
-	if err != nil {
+	item := &m.cacheMenu[index]
+	if err := checkVersion(version, item.Version); err != nil {
 		return err
 	}
+	before := *item
+	if item.Archived && archived {
+		return newError(CodeConflict, "item with product ID %s is already archived", id)
+	} else if !item.Archived && !archived {
+		return newError(CodeConflict, "item with product ID %s is not archived", id)
+	}
+	item.Archived = archived
+	item.ArchivedAt = ""
+	if archived {
+		item.ArchivedAt = time.Now().Format(time.DateTime)
+	}
+	item.Version++
+	if err := dal.NewMenuRepository().WriteMenu(m.cacheMenu); err != nil {
+		return newError(CodeStorage, "failed to archive menu item")
+	}
+	action := "restore"
+	if archived {
+		action = "archive"
+	}
+	recordChange(ctx, action, "menu_item", id, before, *item)
 	return nil
 }
 
-func (m *Menu) AddNewMenuItem(item models.MenuItem) error {
+func (m *Menu) AddNewMenuItem(ctx context.Context, item models.MenuItem) error {
+	menuMu.Lock()
+	defer menuMu.Unlock()
 	err := m.LoadMenuCache()
 	if err != nil {
 		return err
 	}
 	// Check if the ID is already taken
-	if _, exists := m.takenIDMenu[item.ID]; exists {
+	if index, exists := m.takenIDMenu[item.ID]; exists && m.cacheMenu[index].Archived {
+		return newError(CodeConflict, "item with product ID %s is archived, restore it instead", item.ID)
+	} else if exists {
 		return ErrConflict
 	}
 	// Validate item
@@ -115,16 +175,25 @@ func (m *Menu) AddNewMenuItem(item models.MenuItem) error {
 	if err != nil {
 		return err
 	}
+	if err = validateActiveIngredients(item.Ingredients, nil); err != nil {
+		return err
+	}
 
+	item.Version = 1
+	item.Archived = false
+	item.ArchivedAt = ""
 	m.cacheMenu = append(m.cacheMenu, item)
 	if err := dal.NewMenuRepository().WriteMenu(m.cacheMenu); err != nil {
-		return errors.New("failed to save menu item")
+		return newError(CodeStorage, "failed to save menu item")
 	}
+	recordChange(ctx, "create", "menu_item", item.ID, nil, item)
 
 	return nil
 }
 
-func (m *Menu) ModifyMenuItem(item models.MenuItem) error {
+func (m *Menu) ModifyMenuItem(ctx context.Context, item models.MenuItem, version int) error {
+	menuMu.Lock()
+	defer menuMu.Unlock()
 	err := m.LoadMenuCache()
 	if err != nil {
 		return err
@@ -132,7 +201,13 @@ func (m *Menu) ModifyMenuItem(item models.MenuItem) error {
 	index, exists := m.takenIDMenu[item.ID]
 	// Check if the ID is already taken
 	if !exists || index < 0 || index >= len(m.cacheMenu) {
-		return fmt.Errorf("item with product ID %s not found", item.ID)
+		return newNotFoundError("item with product ID %s not found", item.ID)
+	}
+	if err := checkVersion(version, m.cacheMenu[index].Version); err != nil {
+		return err
+	}
+	if m.cacheMenu[index].Archived {
+		return newError(CodeConflict, "item with product ID %s is archived, restore it first", item.ID)
 	}
 	// Validate item
 	if err = validatePostMenu(item); err != nil {
@@ -142,24 +217,32 @@ func (m *Menu) ModifyMenuItem(item models.MenuItem) error {
 	if err != nil {
 		return err
 	}
+	if err = validateActiveIngredients(item.Ingredients, m.cacheMenu[index].Ingredients); err != nil {
+		return err
+	}
+	item.Archived = false
+	item.ArchivedAt = ""
 
-	if m.cacheMenu[index].Description == item.Description &&
-		m.cacheMenu[index].ID == item.ID &&
-		m.cacheMenu[index].Name == item.Name &&
-		m.cacheMenu[index].Price == item.Price &&
-		equalSlices(m.cacheMenu[index].Ingredients, item.Ingredients) {
+	if equalMenuItems(m.cacheMenu[index], item) {
 		return ErrNothingToModify
 	}
 
+	before := m.cacheMenu[index]
+	item.Version = before.Version + 1
 	m.cacheMenu[index] = item
 	if err := dal.NewMenuRepository().WriteMenu(m.cacheMenu); err != nil {
-		return errors.New("failed to modify menu item")
+		return newError(CodeStorage, "failed to modify menu item")
 	}
+	recordChange(ctx, "update", "menu_item", item.ID, before, item)
 
 	return nil
 }
 
-func (m *Menu) DeductMenuProduct(ID string, quantity float64) error {
+// DeductMenuProduct takes the ingredients of a quantity of the product out of the inventory
+// on behalf of the member of staff
+func (m *Menu) DeductMenuProduct(ctx context.Context, ID string, quantity float64, staffID int) error {
+	menuMu.Lock()
+	defer menuMu.Unlock()
 	i := NewInventoryService()
 	err := m.LoadMenuCache()
 	if err != nil {
@@ -171,7 +254,7 @@ func (m *Menu) DeductMenuProduct(ID string, quantity float64) error {
 	}
 	index, exists := m.takenIDMenu[item.ID]
 	if !exists || index < 0 || index >= len(m.cacheMenu) {
-		return fmt.Errorf("item with product ID %s not found", item.ID)
+		return newNotFoundError("item with product ID %s not found", item.ID)
 	}
 	if err = validatePostMenu(item); err != nil {
 		return err
@@ -181,12 +264,117 @@ func (m *Menu) DeductMenuProduct(ID string, quantity float64) error {
 		return err
 	}
 	for _, ingredient := range item.Ingredients {
-		if err := i.DeductInventoryItem(ingredient.IngredientID, ingredient.Quantity*quantity); err != nil {
+		if err := i.DeductInventoryItem(ctx, ingredient.IngredientID, ingredient.Quantity*quantity, staffID); err != nil {
 			return err
 		}
 	}
 	if err := dal.NewMenuRepository().WriteMenu(m.cacheMenu); err != nil {
-		return errors.New("failed to modify menu item")
+		return newError(CodeStorage, "failed to modify menu item")
+	}
+	return nil
+}
+
+// RestockMenuProduct puts the ingredients of a quantity of the product back into the inventory
+// on behalf of the member of staff
+func (m *Menu) RestockMenuProduct(ctx context.Context, ID string, quantity float64, staffID int) error {
+	i := NewInventoryService()
+	item, err := m.GetMenuByID(ID)
+	if err != nil {
+		return err
+	}
+	for _, ingredient := range item.Ingredients {
+		if err := i.RestockInventoryItem(ctx, ingredient.IngredientID, ingredient.Quantity*quantity, staffID); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// ImportMenu checks every imported item and saves them all, or none if any row has an error.
+// A dry run only reports what would change.
+func (m *Menu) ImportMenu(ctx context.Context, items []models.MenuItem, mode string, dryRun bool) (models.ImportResult, error) {
+	if err := validateImportMode(mode); err != nil {
+		return models.ImportResult{}, err
+	}
+	menuMu.Lock()
+	defer menuMu.Unlock()
+	err := m.LoadMenuCache()
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+	result := models.ImportResult{Mode: mode, DryRun: dryRun}
+	menu := append([]models.MenuItem(nil), m.cacheMenu...)
+	if mode == ImportReplace {
+		menu = nil
+	}
+	rows := make(map[string]int)
+	for i, item := range items {
+		row := i + 1
+		if first, exists := rows[item.ID]; exists {
+			addImportError(&result, row, item.ID, fmt.Errorf("product ID is already imported in row %d", first))
+			continue
+		}
+		rows[item.ID] = row
+		if err := validatePostMenu(item); err != nil {
+			addImportError(&result, row, item.ID, err)
+			continue
+		}
+		if err := validatePostMenuIngredients(item.Ingredients); err != nil {
+			addImportError(&result, row, item.ID, err)
+			continue
+		}
+
+		// Importing an archived item restores it
+		index, exists := m.takenIDMenu[item.ID]
+		var current []models.MenuItemIngredient
+		if exists {
+			current = m.cacheMenu[index].Ingredients
+		}
+		if err := validateActiveIngredients(item.Ingredients, current); err != nil {
+			addImportError(&result, row, item.ID, err)
+			continue
+		}
+		item.Archived = false
+		item.ArchivedAt = ""
+		if !exists {
+			item.Version = 1
+			result.Created++
+		} else if equalMenuItems(m.cacheMenu[index], item) && !m.cacheMenu[index].Archived {
+			item.Version = m.cacheMenu[index].Version
+			result.Unchanged++
+		} else {
+			item.Version = m.cacheMenu[index].Version + 1
+			result.Updated++
+		}
+		if exists && mode == ImportUpsert {
+			menu[index] = item
+		} else {
+			menu = append(menu, item)
+		}
+	}
+	if mode == ImportReplace {
+		archivedAt := time.Now().Format(time.DateTime)
+		for _, item := range m.cacheMenu {
+			if _, exists := rows[item.ID]; exists {
+				continue
+			}
+			if !item.Archived {
+				item.Archived = true
+				item.ArchivedAt = archivedAt
+				item.Version++
+				result.Deleted++
+			}
+			menu = append(menu, item)
+		}
+	}
+
+	if len(result.Errors) > 0 || dryRun {
+		return result, nil
+	}
+	if err := dal.NewMenuRepository().WriteMenu(menu); err != nil {
+		return models.ImportResult{}, newError(CodeStorage, "failed to save imported menu items")
+	}
+	recordChange(ctx, "import", "menu_item", "", nil, result)
+	m.cacheMenu = menu
+	return result, nil
+}
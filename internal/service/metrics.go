@@ -0,0 +1,56 @@
+package service
+
+import (
+	"maps"
+	"sync"
+)
+
+// OrderMetrics counts what happened to orders since the server started. Like every Prometheus
+// counter they start over from zero on a restart.
+type OrderMetrics struct {
+	Created int
+	Closed  int
+	// Revenue is the sum of the totals of the closed orders, before refunds
+	Revenue float64
+	// CloseFailures counts the closes that failed by error code, e.g. insufficient_stock
+	CloseFailures map[string]int
+}
+
+var orderMetrics = struct {
+	sync.Mutex
+	OrderMetrics
+}{OrderMetrics: OrderMetrics{CloseFailures: make(map[string]int)}}
+
+// GetOrderMetrics returns a copy of the order counters
+func GetOrderMetrics() OrderMetrics {
+	orderMetrics.Lock()
+	defer orderMetrics.Unlock()
+	metrics := orderMetrics.OrderMetrics
+	metrics.CloseFailures = maps.Clone(orderMetrics.CloseFailures)
+	return metrics
+}
+
+func countOrderCreated() {
+	orderMetrics.Lock()
+	defer orderMetrics.Unlock()
+	orderMetrics.Created++
+}
+
+func countOrderClosed(total float64) {
+	orderMetrics.Lock()
+	defer orderMetrics.Unlock()
+	orderMetrics.Closed++
+	orderMetrics.Revenue = roundMoney(orderMetrics.Revenue + total)
+}
+
+// countCloseFailure counts a failed close by the code of its error, "other" for errors
+// from outside the service layer
+func countCloseFailure(err error) {
+	code := ErrorCode(err)
+	if code == "" {
+		code = "other"
+	}
+	orderMetrics.Lock()
+	defer orderMetrics.Unlock()
+	orderMetrics.CloseFailures[code]++
+}
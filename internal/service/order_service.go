@@ -1,27 +1,55 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"hot-cofee/internal/dal"
 	"hot-cofee/models"
 )
 
+var (
+	ErrOrderNotPaid     = newError(CodeOrderNotPaid, "order is not fully paid")
+	ErrOrderHasPayments = newError(CodeOrderHasPayments, "order has payments")
+	ErrOrderNotClosed   = newError(CodeOrderNotClosed, "order is not closed")
+)
+
+const (
+	PaymentCash    = "cash"
+	PaymentCard    = "card"
+	PaymentVoucher = "voucher"
+)
+
 type Order struct {
 	cacheOrders   []models.Order
 	takenIDOrders map[int]int
 }
 
+// ordersMu serializes the changes of orders from loading to saving them, so that none of them
+// is lost and version checks hold
+var ordersMu sync.Mutex
+
 type OrderService interface {
 	GetAllOrders() ([]models.Order, error)
+	GetOrders(filter models.OrderFilter) ([]models.Order, int, error)
 	GetOrderByID(ID int) (models.Order, error)
-	AddNewOrder(order models.Order) error
-	CloseOrder(ID int) error
-	DeleteOrder(ID int) error
-	ModifyOrder(order models.Order, ID int) error
+	AddNewOrder(ctx context.Context, order models.Order) (models.Order, error)
+	CloseOrder(ctx context.Context, ID, staffID int) error
+	DeleteOrder(ctx context.Context, ID, version int) error
+	ModifyOrder(ctx context.Context, order models.Order, ID, version int) error
+	PatchOrder(ctx context.Context, order models.Order, ID, version int) error
 	LoadOrdersCache() error
+	AddPayment(ctx context.Context, ID int, payment models.Payment) (models.Payment, error)
+	RefundOrder(ctx context.Context, ID, staffID int, refund models.Refund) (models.Refund, error)
+	SetItemStatus(ctx context.Context, ID int, productID, status string) (models.Order, error)
+	AdvanceItem(ctx context.Context, ID int, productID string) (models.Order, error)
 }
 
 func NewOrderService() OrderService {
@@ -34,7 +62,7 @@ func NewOrderService() OrderService {
 func (o *Order) findOrderIndexByID(ID int) (int, error) {
 	index, exists := o.takenIDOrders[ID]
 	if !exists || index < 0 || index >= len(o.cacheOrders) {
-		return -1, fmt.Errorf("order with ID %d not found", ID)
+		return -1, newNotFoundError("order with ID %d not found", ID)
 	}
 	return index, nil
 }
@@ -60,12 +88,86 @@ func (o *Order) GetAllOrders() ([]models.Order, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(o.cacheOrders) == 0 {
-		return o.cacheOrders, errors.New("no orders in orders in orders storage")
-	}
 	return o.cacheOrders, nil
 }
 
+// GetOrders returns one page of the orders matching the filter together with
+// the total number of matches before pagination
+func (o *Order) GetOrders(filter models.OrderFilter) ([]models.Order, int, error) {
+	if err := validateOrderFilter(filter); err != nil {
+		return nil, 0, err
+	}
+	err := o.LoadOrdersCache()
+	if err != nil {
+		return nil, 0, err
+	}
+	orders := []models.Order{}
+	for _, order := range o.cacheOrders {
+		if matchesOrderFilter(order, filter) {
+			orders = append(orders, order)
+		}
+	}
+	sortOrders(orders, filter.Sort)
+
+	total := len(orders)
+	if filter.Offset >= total {
+		return []models.Order{}, total, nil
+	}
+	orders = orders[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(orders) {
+		orders = orders[:filter.Limit]
+	}
+	return orders, total, nil
+}
+
+func matchesOrderFilter(order models.Order, filter models.OrderFilter) bool {
+	if filter.Status != "" && !strings.EqualFold(order.Status, filter.Status) {
+		return false
+	}
+	if filter.CustomerID != 0 && order.CustomerID != filter.CustomerID {
+		return false
+	}
+	if filter.Customer != "" && !strings.Contains(strings.ToLower(order.CustomerName), strings.ToLower(filter.Customer)) {
+		return false
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		createdAt, err := time.ParseInLocation(time.DateTime, order.CreatedAt, time.Local)
+		if err != nil {
+			return false
+		}
+		if !filter.From.IsZero() && createdAt.Before(filter.From) {
+			return false
+		}
+		if !filter.To.IsZero() && createdAt.After(filter.To) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortOrders(orders []models.Order, sortBy string) {
+	desc := strings.HasPrefix(sortBy, "-")
+	field := strings.TrimPrefix(sortBy, "-")
+	sort.SliceStable(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		if desc {
+			a, b = b, a
+		}
+		switch field {
+		case "created_at":
+			// created_at is stored in time.DateTime layout, so it sorts lexically
+			if a.CreatedAt != b.CreatedAt {
+				return a.CreatedAt < b.CreatedAt
+			}
+		case "customer":
+			if a.CustomerName != b.CustomerName {
+				return a.CustomerName < b.CustomerName
+			}
+		}
+		return a.ID < b.ID
+	})
+}
+
 func (o *Order) GetOrderByID(id int) (models.Order, error) {
 	err := o.LoadOrdersCache()
 	if err != nil {
@@ -78,10 +180,14 @@ func (o *Order) GetOrderByID(id int) (models.Order, error) {
 	return o.cacheOrders[index], nil
 }
 
-func (o *Order) AddNewOrder(order models.Order) error {
+// AddNewOrder prices and saves a new order and returns it with its estimated ready time.
+// CreatedBy is the member of staff taking the order, if any. The context ties the logs to the request.
+func (o *Order) AddNewOrder(ctx context.Context, order models.Order) (models.Order, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
 	err := o.LoadOrdersCache()
 	if err != nil {
-		return err
+		return models.Order{}, err
 	}
 	if len(o.cacheOrders) == 0 {
 		order.ID = 0
@@ -89,22 +195,66 @@ func (o *Order) AddNewOrder(order models.Order) error {
 		lastId := o.cacheOrders[len(o.cacheOrders)-1].ID
 		order.ID = lastId + 1
 	}
+	if order.CustomerID != 0 && order.CustomerName == "" {
+		customer, err := NewCustomerService().GetCustomerByID(order.CustomerID)
+		if err != nil {
+			return models.Order{}, err
+		}
+		order.CustomerName = customer.Name
+	}
 	if err := validateOrder(order); err != nil {
-		return err
+		return models.Order{}, err
+	}
+	if err := validateActiveProducts(order, nil); err != nil {
+		return models.Order{}, err
 	}
-	order.Status = "Open"
+	order.Status = OrderOpen
+	order.Version = 1
+	order.ClosedBy = 0
+	order.ClosedShiftID = 0
+	order.EstimatedReadyAt = ""
 	order.CreatedAt = time.Now().Format(time.DateTime)
+	for i := range order.Items {
+		order.Items[i].Status = ItemQueued
+	}
 	if _, exists := o.takenIDOrders[order.ID]; exists {
-		return ErrConflict
+		return models.Order{}, ErrConflict
+	}
+	for i := range order.Items {
+		order.Items[i].UnitPrice = 0
+	}
+	if err := priceOrder(&order, nil); err != nil {
+		return models.Order{}, err
+	}
+	l := NewLoyaltyService()
+	if err := l.RedeemForOrder(ctx, &order); err != nil {
+		return models.Order{}, err
 	}
 	o.cacheOrders = append(o.cacheOrders, order)
 	if err := dal.NewOrderRepository().WriteOrder(o.cacheOrders); err != nil {
-		return err
+		return models.Order{}, errors.Join(err, l.ReverseRedemption(ctx, order))
 	}
-	return nil
+	recordChange(ctx, "create", "order", strconv.Itoa(order.ID), nil, order)
+	publishOrderEvent(EventOrderCreated, order)
+	countOrderCreated()
+	if estimates, err := EstimateReadyTimes(); err == nil {
+		order.EstimatedReadyAt = estimates[order.ID]
+	} else {
+		slog.ErrorContext(ctx, "Failed to estimate ready time", "order", order.ID, "error", err)
+	}
+	return order, nil
 }
 
-func (o *Order) CloseOrder(ID int) error {
+// CloseOrder deducts the ingredients of a paid order and closes it on behalf of the member
+// of staff, recording the shift they are clocked in to. The context ties the logs to the request.
+func (o *Order) CloseOrder(ctx context.Context, ID, staffID int) (err error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+	defer func() {
+		if err != nil {
+			countCloseFailure(err)
+		}
+	}()
 	m := NewMenuService()
 	// Load orders from cache
 	order, err := o.GetOrderByID(ID)
@@ -121,66 +271,396 @@ func (o *Order) CloseOrder(ID int) error {
 	if err := validateCloseOrder(order); err != nil {
 		return err
 	}
+	totals, err := orderAmounts(order)
+	if err != nil {
+		return err
+	}
+	if roundMoney(order.AmountPaid) < totals.Total {
+		return fmt.Errorf("%w: %.2f of %.2f paid", ErrOrderNotPaid, order.AmountPaid, totals.Total)
+	}
+	// The shift is looked up first, so that failing to read it does not leave the stock deducted
+	shiftID, err := openShiftID(staffID)
+	if err != nil {
+		return err
+	}
 	for _, product := range order.Items {
 		if err := validateDeductCheckIngredients(product.ProductID, float64(product.Quantity)); err != nil {
 			return err
 		}
-		if err := m.DeductMenuProduct(product.ProductID, float64(product.Quantity)); err != nil {
+		if err := m.DeductMenuProduct(ctx, product.ProductID, float64(product.Quantity), staffID); err != nil {
 			return err
 		}
 	}
-	order.Status = "Closed"
-	o.cacheOrders[ID] = order
-	if err := dal.NewOrderRepository().WriteOrder(o.cacheOrders); err != nil {
+	original := order
+	order.Status = OrderClosed
+	order.ClosedBy = staffID
+	order.ClosedShiftID = shiftID
+	order.Version++
+	index, err := o.findOrderIndexByID(ID)
+	if err != nil {
 		return err
 	}
+	o.cacheOrders[index] = order
+	if err := dal.NewOrderRepository().WriteOrder(o.cacheOrders); err != nil {
+		return newError(CodeStorage, "failed to close order")
+	}
+	recordChange(ctx, "close", "order", strconv.Itoa(ID), original, order)
+	publishOrderEvent(EventOrderClosed, order)
+	countOrderClosed(totals.Total)
+	if err := NewLoyaltyService().EarnForOrder(ctx, order); err != nil {
+		slog.ErrorContext(ctx, "Failed to credit loyalty points", "order", order.ID, "error", err)
+	}
 	return nil
 }
 
-func (o *Order) DeleteOrder(ID int) error {
+// DeleteOrder removes an order without payments if it still has the given version or any is given
+func (o *Order) DeleteOrder(ctx context.Context, ID, version int) error {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
 	err := o.LoadOrdersCache()
 	if err != nil {
 		return err
 	}
 	index, exists := o.takenIDOrders[ID]
 	if !exists || index < 0 || index >= len(o.cacheOrders) {
-		return fmt.Errorf("order with id  %d not found", ID)
+		return newNotFoundError("order with ID %d not found", ID)
+	}
+	order := o.cacheOrders[index]
+	if err := checkVersion(version, order.Version); err != nil {
+		return err
+	}
+	if len(order.Payments) > 0 {
+		return fmt.Errorf("%w: order %d", ErrOrderHasPayments, ID)
 	}
 	o.cacheOrders = append(o.cacheOrders[:index], o.cacheOrders[index+1:]...)
 	err = dal.NewOrderRepository().WriteOrder(o.cacheOrders)
 	if err != nil {
-		return err
+		return newError(CodeStorage, "failed to delete order")
+	}
+	recordChange(ctx, "delete", "order", strconv.Itoa(ID), order, nil)
+	publishOrderEvent(EventOrderCancelled, order)
+	// Cancelling an open order gives back the points spent on its rewards
+	if order.Status != OrderClosed {
+		return NewLoyaltyService().ReverseRedemption(ctx, order)
 	}
 	return nil
 }
 
-func (o *Order) ModifyOrder(order models.Order, ID int) error {
+// ModifyOrder updates an order, fields left empty keep their current value
+func (o *Order) ModifyOrder(ctx context.Context, order models.Order, ID, version int) error {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
 	err := o.LoadOrdersCache()
 	if err != nil {
 		return err
 	}
 	index, exists := o.takenIDOrders[ID]
 	if !exists || index < 0 || index >= len(o.cacheOrders) {
-		return fmt.Errorf("order with id  %d not found", order.ID)
+		return newNotFoundError("order with ID %d not found", order.ID)
+	}
+	if err := checkVersion(version, o.cacheOrders[index].Version); err != nil {
+		return err
+	}
+	return o.replaceOrder(ctx, orderInit(order, o.cacheOrders[index]), index)
+}
+
+// PatchOrder replaces an order with its patched version. Unlike ModifyOrder empty fields
+// are taken as they are, so a patch can clear the customer or the promo code.
+func (o *Order) PatchOrder(ctx context.Context, order models.Order, ID, version int) error {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+	err := o.LoadOrdersCache()
+	if err != nil {
+		return err
+	}
+	index, err := o.findOrderIndexByID(ID)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(version, o.cacheOrders[index].Version); err != nil {
+		return err
+	}
+	return o.replaceOrder(ctx, order, index)
+}
+
+// replaceOrder reprices and validates the new content of the cached order at index and saves it
+func (o *Order) replaceOrder(ctx context.Context, order models.Order, index int) error {
+	// Payments and refunds are only recorded through AddPayment and RefundOrder
+	order.Payments = o.cacheOrders[index].Payments
+	order.AmountPaid = o.cacheOrders[index].AmountPaid
+	order.Refunds = o.cacheOrders[index].Refunds
+	order.Refunded = o.cacheOrders[index].Refunded
+	// So is who took and closed the order
+	order.CreatedBy = o.cacheOrders[index].CreatedBy
+	order.ClosedBy = o.cacheOrders[index].ClosedBy
+	order.ClosedShiftID = o.cacheOrders[index].ClosedShiftID
+	order.EstimatedReadyAt = ""
+	// Line statuses are only changed through SetItemStatus, added lines start queued
+	statuses := make(map[string]string)
+	for _, item := range o.cacheOrders[index].Items {
+		statuses[item.ProductID] = item.Status
+	}
+	for i, item := range order.Items {
+		if status, exists := statuses[item.ProductID]; exists {
+			order.Items[i].Status = status
+		} else {
+			order.Items[i].Status = ItemQueued
+		}
+	}
+	if err := priceOrder(&order, &o.cacheOrders[index]); err != nil {
+		return err
 	}
-	order = orderInit(order, o.cacheOrders[index])
 	if err := validateModifying(order, o.cacheOrders[index]); err != nil {
 		return err
 	}
-	if err = validateOrder(order); err != nil {
+	if err := validateOrder(order); err != nil {
+		return err
+	}
+	if err := validateActiveProducts(order, &o.cacheOrders[index]); err != nil {
 		return err
 	}
+	updateReadyStatus(&order)
+	// A changed customer or changed rewards are charged anew
+	original := o.cacheOrders[index]
+	undoRedemption, err := NewLoyaltyService().ReplaceRedemption(ctx, original, &order)
+	if err != nil {
+		return err
+	}
+	order.Version = original.Version + 1
 	o.cacheOrders[index] = order
 	if err := dal.NewOrderRepository().WriteOrder(o.cacheOrders); err != nil {
-		return errors.New("failed to modify order")
+		o.cacheOrders[index] = original
+		return errors.Join(newError(CodeStorage, "failed to modify order"), undoRedemption())
 	}
+	recordChange(ctx, "update", "order", strconv.Itoa(order.ID), original, order)
+	publishOrderEvent(EventOrderModified, order)
 	return nil
 }
 
+// AddPayment records a tender against an open order. A zero amount pays the rest of the order,
+// cash may be tendered above the amount and the difference is given back as change.
+func (o *Order) AddPayment(ctx context.Context, ID int, payment models.Payment) (models.Payment, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+	err := o.LoadOrdersCache()
+	if err != nil {
+		return models.Payment{}, err
+	}
+	index, err := o.findOrderIndexByID(ID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	order := o.cacheOrders[index]
+	if order.Status == OrderClosed {
+		return models.Payment{}, newError(CodeOrderClosed, "order is already closed")
+	}
+	totals, err := orderAmounts(order)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	due := roundMoney(totals.Total - order.AmountPaid)
+	if payment.Amount == 0 {
+		payment.Amount = due
+	}
+	if err := validatePayment(payment, due); err != nil {
+		return models.Payment{}, err
+	}
+	if payment.Method == PaymentCash && payment.Tendered > 0 {
+		payment.Change = roundMoney(payment.Tendered - payment.Amount - payment.Tip)
+	}
+	payment.ID = len(order.Payments) + 1
+	payment.CreatedAt = time.Now().Format(time.DateTime)
+
+	original := order
+	order.Payments = append(order.Payments, payment)
+	order.AmountPaid = roundMoney(order.AmountPaid + payment.Amount)
+	order.Version++
+	o.cacheOrders[index] = order
+	if err := dal.NewOrderRepository().WriteOrder(o.cacheOrders); err != nil {
+		return models.Payment{}, newError(CodeStorage, "failed to save payment")
+	}
+	recordChange(ctx, "pay", "order", strconv.Itoa(ID), original, order)
+	publishOrderEvent(EventOrderModified, order)
+	return payment, nil
+}
+
+// RefundOrder gives back some lines of a closed order. Every refunded unit is worth what the
+// customer actually paid for it, so the discount and tax of the order are refunded in proportion.
+// With Restock set the ingredients of the refunded units go back into the inventory on behalf of
+// the member of staff.
+func (o *Order) RefundOrder(ctx context.Context, ID, staffID int, refund models.Refund) (models.Refund, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+	err := o.LoadOrdersCache()
+	if err != nil {
+		return models.Refund{}, err
+	}
+	index, err := o.findOrderIndexByID(ID)
+	if err != nil {
+		return models.Refund{}, err
+	}
+	order := o.cacheOrders[index]
+	if order.Status != OrderClosed {
+		return models.Refund{}, fmt.Errorf("%w: only closed orders can be refunded", ErrOrderNotClosed)
+	}
+	if err := validateRefund(refund, order); err != nil {
+		return models.Refund{}, err
+	}
+	totals, err := orderAmounts(order)
+	if err != nil {
+		return models.Refund{}, err
+	}
+	unitPrices := make(map[string]float64)
+	for _, item := range order.Items {
+		unitPrices[item.ProductID] = item.UnitPrice
+	}
+	m := NewMenuService()
+	refund.Amount, refund.Tax = 0, 0
+	for i, item := range refund.Items {
+		unitPrice := unitPrices[item.ProductID]
+		if unitPrice == 0 {
+			product, err := m.GetMenuByID(item.ProductID)
+			if err != nil {
+				return models.Refund{}, err
+			}
+			unitPrice = product.Price
+		}
+		// A line gives back its share of what was paid, nothing if the order was free
+		if totals.Gross == 0 {
+			refund.Items[i].Amount = 0
+			continue
+		}
+		lineGross := float64(item.Quantity) * unitPrice
+		refund.Items[i].Amount = roundMoney(lineGross * totals.Total / totals.Gross)
+		refund.Amount += refund.Items[i].Amount
+		refund.Tax += lineGross * totals.Tax / totals.Gross
+	}
+	refund.Amount = roundMoney(refund.Amount)
+	refund.Tax = roundMoney(refund.Tax)
+	refund.ID = len(order.Refunds) + 1
+	refund.CreatedAt = time.Now().Format(time.DateTime)
+
+	// The refund is saved before the stock is put back, so that a failed save cannot restock
+	// twice when it is retried; a failed restock takes the refund back again
+	original := order
+	order.Refunds = append(order.Refunds, refund)
+	order.Refunded = roundMoney(order.Refunded + refund.Amount)
+	order.Version++
+	o.cacheOrders[index] = order
+	if err := dal.NewOrderRepository().WriteOrder(o.cacheOrders); err != nil {
+		o.cacheOrders[index] = original
+		return models.Refund{}, newError(CodeStorage, "failed to save refund")
+	}
+	if refund.Restock {
+		for i, item := range refund.Items {
+			if err := m.RestockMenuProduct(ctx, item.ProductID, float64(item.Quantity), staffID); err != nil {
+				return models.Refund{}, errors.Join(err, o.undoRefund(ctx, index, staffID, original, refund.Items[:i]))
+			}
+		}
+	}
+	recordChange(ctx, "refund", "order", strconv.Itoa(ID), original, order)
+	publishOrderEvent(EventOrderModified, order)
+	return refund, nil
+}
+
+// undoRefund restores the order as it was before a refund whose restock failed and deducts
+// the lines that were already restocked
+func (o *Order) undoRefund(ctx context.Context, index, staffID int, original models.Order, restocked []models.RefundItem) error {
+	m := NewMenuService()
+	var errs []error
+	for _, item := range restocked {
+		errs = append(errs, m.DeductMenuProduct(ctx, item.ProductID, float64(item.Quantity), staffID))
+	}
+	o.cacheOrders[index] = original
+	if err := dal.NewOrderRepository().WriteOrder(o.cacheOrders); err != nil {
+		errs = append(errs, newError(CodeStorage, "failed to take back refund"))
+	}
+	return errors.Join(errs...)
+}
+
+// SetItemStatus records the preparation status of one line of an order.
+// The order becomes Ready as soon as all of its lines are done.
+func (o *Order) SetItemStatus(ctx context.Context, ID int, productID, status string) (models.Order, error) {
+	return o.setItemStatus(ctx, "set_item_status", ID, productID, status)
+}
+
+// setItemStatus sets the status of the line, recording the change as the audit action
+func (o *Order) setItemStatus(ctx context.Context, action string, ID int, productID, status string) (models.Order, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+	err := o.LoadOrdersCache()
+	if err != nil {
+		return models.Order{}, err
+	}
+	index, err := o.findOrderIndexByID(ID)
+	if err != nil {
+		return models.Order{}, err
+	}
+	order := o.cacheOrders[index]
+	if err := validateItemStatus(order, status); err != nil {
+		return models.Order{}, err
+	}
+	line := -1
+	for i, item := range order.Items {
+		if item.ProductID == productID {
+			line = i
+		}
+	}
+	if line < 0 {
+		return models.Order{}, newNotFoundError("product %s is not part of order %d", productID, ID)
+	}
+	if itemStatus(order.Items[line]) == status {
+		return models.Order{}, ErrNothingToModify
+	}
+
+	// Work on a copy so that the cached order keeps its original lines if writing fails
+	order.Items = append([]models.OrderItem(nil), order.Items...)
+	original := o.cacheOrders[index]
+	order.Items[line].Status = status
+	wasReady := order.Status == OrderReady
+	updateReadyStatus(&order)
+	order.Version++
+	o.cacheOrders[index] = order
+	if err := dal.NewOrderRepository().WriteOrder(o.cacheOrders); err != nil {
+		return models.Order{}, newError(CodeStorage, "failed to modify order")
+	}
+	recordChange(ctx, action, "order", strconv.Itoa(ID), original, order)
+	publishOrderEvent(EventOrderModified, order)
+	if order.Status == OrderReady && !wasReady {
+		publishOrderEvent(EventOrderReady, order)
+	}
+	return order, nil
+}
+
+// AdvanceItem moves one line of an order to its next preparation status
+func (o *Order) AdvanceItem(ctx context.Context, ID int, productID string) (models.Order, error) {
+	order, err := o.GetOrderByID(ID)
+	if err != nil {
+		return models.Order{}, err
+	}
+	for _, item := range order.Items {
+		if item.ProductID != productID {
+			continue
+		}
+		switch itemStatus(item) {
+		case ItemQueued:
+			return o.setItemStatus(ctx, "advance_item", ID, productID, ItemInProgress)
+		case ItemInProgress:
+			return o.setItemStatus(ctx, "advance_item", ID, productID, ItemDone)
+		default:
+			return models.Order{}, newError(CodeConflict, "product %s of order %d is already done", productID, ID)
+		}
+	}
+	return models.Order{}, newNotFoundError("product %s is not part of order %d", productID, ID)
+}
+
 func orderInit(modifiedOrder, originalOrder models.Order) models.Order {
 	if modifiedOrder.CreatedAt == "" {
 		modifiedOrder.CreatedAt = originalOrder.CreatedAt
 	}
+	if modifiedOrder.CustomerID == 0 {
+		modifiedOrder.CustomerID = originalOrder.CustomerID
+	}
 	if modifiedOrder.CustomerName == "" {
 		modifiedOrder.CustomerName = originalOrder.CustomerName
 	}
@@ -190,5 +670,11 @@ func orderInit(modifiedOrder, originalOrder models.Order) models.Order {
 	if modifiedOrder.Status == "" {
 		modifiedOrder.Status = originalOrder.Status
 	}
+	if modifiedOrder.Rewards == nil {
+		modifiedOrder.Rewards = originalOrder.Rewards
+	}
+	if modifiedOrder.PromoCode == "" {
+		modifiedOrder.PromoCode = originalOrder.PromoCode
+	}
 	return modifiedOrder
 }
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"hot-cofee/models"
+)
+
+func TestRefundOrder(t *testing.T) {
+	// 11 of goods with 10% off and 10% tax on the rest: 9.90 + 0.99 tax = 10.89
+	order := models.Order{
+		ID:           1,
+		CustomerName: "Ann",
+		Status:       OrderClosed,
+		CreatedAt:    "2026-10-01 09:00:00",
+		Items: []models.OrderItem{
+			{ProductID: "latte", Quantity: 2, UnitPrice: 4, Status: ItemDone},
+			{ProductID: "muffin", Quantity: 1, UnitPrice: 3, Status: ItemDone},
+		},
+		Subtotal:   11,
+		Discount:   1.1,
+		TaxTotal:   0.99,
+		Total:      10.89,
+		AmountPaid: 10.89,
+		Version:    3,
+	}
+	stock := map[string]float64{"milk": 1000, "espresso_shot": 10, "flour": 500}
+
+	tests := []struct {
+		name   string
+		refund models.Refund
+		// noFlour leaves flour out of the inventory, so that restocking a muffin fails
+		noFlour bool
+		failed  bool
+		amount  float64
+		tax     float64
+		stock   map[string]float64
+	}{
+		{
+			name:   "one of two lattes",
+			refund: models.Refund{Reason: "spilled", Items: []models.RefundItem{{ProductID: "latte", Quantity: 1}}},
+			amount: 3.96, tax: 0.36, stock: stock,
+		},
+		{
+			name:   "the muffin",
+			refund: models.Refund{Reason: "stale", Items: []models.RefundItem{{ProductID: "muffin", Quantity: 1}}},
+			amount: 2.97, tax: 0.27, stock: stock,
+		},
+		{
+			name:   "whole order",
+			refund: models.Refund{Reason: "wrong order", Items: []models.RefundItem{{ProductID: "latte", Quantity: 2}, {ProductID: "muffin", Quantity: 1}}},
+			amount: 10.89, tax: 0.99, stock: stock,
+		},
+		{
+			name: "restocked",
+			refund: models.Refund{Reason: "not served", Restock: true,
+				Items: []models.RefundItem{{ProductID: "latte", Quantity: 1}, {ProductID: "muffin", Quantity: 1}}},
+			amount: 6.93, tax: 0.63, stock: map[string]float64{"milk": 1200, "espresso_shot": 11, "flour": 600},
+		},
+		{
+			name: "failed restock is rolled back",
+			refund: models.Refund{Reason: "not served", Restock: true,
+				Items: []models.RefundItem{{ProductID: "latte", Quantity: 1}, {ProductID: "muffin", Quantity: 1}}},
+			noFlour: true, failed: true, stock: map[string]float64{"milk": 1000, "espresso_shot": 10},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useEmptyStorage(t)
+			writeStorage(t, "menu_items.json", testMenu)
+			writeStorage(t, "orders.json", []models.Order{order})
+			var inventory []models.InventoryItem
+			for _, id := range []string{"milk", "espresso_shot", "flour"} {
+				if id == "flour" && test.noFlour {
+					continue
+				}
+				inventory = append(inventory, models.InventoryItem{IngredientID: id, Name: id, Quantity: stock[id], Unit: "g", Version: 1})
+			}
+			writeStorage(t, "inventory.json", inventory)
+
+			o := &Order{}
+			refund, err := o.RefundOrder(context.Background(), order.ID, 0, test.refund)
+			if test.failed && err == nil {
+				t.Fatalf("refund succeeded, expected the restock to fail")
+			} else if !test.failed && err != nil {
+				t.Fatalf("RefundOrder failed: %v", err)
+			}
+			if refund.Amount != test.amount || refund.Tax != test.tax {
+				t.Fatalf("got amount %v with tax %v, expected %v with tax %v", refund.Amount, refund.Tax, test.amount, test.tax)
+			}
+
+			saved, err := o.GetOrderByID(order.ID)
+			if err != nil {
+				t.Fatalf("GetOrderByID failed: %v", err)
+			}
+			if test.failed {
+				if len(saved.Refunds) != 0 || saved.Refunded != 0 || saved.Version != order.Version {
+					t.Fatalf("the order kept the failed refund: %+v", saved)
+				}
+			} else if saved.Refunded != test.amount || len(saved.Refunds) != 1 || saved.Version != order.Version+1 {
+				t.Fatalf("got refunded %v in %d refunds at version %d, expected %v in 1 at version %d",
+					saved.Refunded, len(saved.Refunds), saved.Version, test.amount, order.Version+1)
+			}
+
+			items, err := NewInventoryService().GetAllInventory()
+			if err != nil {
+				t.Fatalf("GetAllInventory failed: %v", err)
+			}
+			quantities := make(map[string]float64)
+			for _, item := range items {
+				quantities[item.IngredientID] = item.Quantity
+			}
+			if !reflect.DeepEqual(quantities, test.stock) {
+				t.Fatalf("got stock %v, expected %v", quantities, test.stock)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"time"
+
+	"hot-cofee/models"
+)
+
+// priceOrder snapshots the unit price of every line, evaluates promotions, loyalty
+// rewards and taxes and fills in the subtotal, discount, tax and total of the order. Lines that were
+// already on the original order keep the price they were sold at.
+func priceOrder(order *models.Order, original *models.Order) error {
+	m := NewMenuService()
+	originalPrices := make(map[string]float64)
+	if original != nil {
+		for _, item := range original.Items {
+			originalPrices[item.ProductID] = item.UnitPrice
+		}
+	}
+	subtotal := 0.0
+	for i, item := range order.Items {
+		if price, exists := originalPrices[item.ProductID]; exists && price > 0 {
+			order.Items[i].UnitPrice = price
+		} else {
+			product, err := m.GetMenuByID(item.ProductID)
+			if err != nil {
+				return err
+			}
+			order.Items[i].UnitPrice = product.Price
+		}
+		subtotal += float64(item.Quantity) * order.Items[i].UnitPrice
+	}
+	order.Subtotal = roundMoney(subtotal)
+
+	// Promotions are evaluated at the time the order was placed, not when it is modified
+	at, err := time.ParseInLocation(time.DateTime, order.CreatedAt, time.Local)
+	if err != nil {
+		at = time.Now()
+	}
+	if err := NewPromotionService().EvaluatePromotions(order, at); err != nil {
+		return err
+	}
+	order.Discount = 0
+	for _, promotion := range order.Promotions {
+		order.Discount += promotion.Discount
+	}
+	if err := NewLoyaltyService().ApplyRewards(order); err != nil {
+		return err
+	}
+	order.Discount = roundMoney(order.Discount)
+	if err := NewTaxService().ApplyTaxes(order); err != nil {
+		return err
+	}
+	order.Total = order.Subtotal - order.Discount
+	for _, line := range order.TaxLines {
+		if !line.Inclusive {
+			order.Total += line.Amount
+		}
+	}
+	order.Total = roundMoney(order.Total)
+	return nil
+}
+
+// orderTotals holds what an order is worth for the sales reports
+type orderTotals struct {
+	Gross       float64
+	Discount    float64
+	Tax         float64
+	Total       float64
+	Refunded    float64
+	RefundedTax float64
+}
+
+// orderAmounts returns the amounts of an order. Orders stored before prices were
+// snapshotted are priced with the current menu and carry no tax.
+func orderAmounts(order models.Order) (orderTotals, error) {
+	refundedTax := 0.0
+	for _, refund := range order.Refunds {
+		refundedTax += refund.Tax
+	}
+	if order.Subtotal > 0 {
+		return orderTotals{order.Subtotal, order.Discount, order.TaxTotal, order.Total, order.Refunded, refundedTax}, nil
+	}
+	m := NewMenuService()
+	gross := 0.0
+	for _, item := range order.Items {
+		product, err := m.GetMenuByID(item.ProductID)
+		if err != nil {
+			return orderTotals{}, err
+		}
+		gross += float64(item.Quantity) * product.Price
+	}
+	gross = roundMoney(gross)
+	return orderTotals{gross, order.Discount, 0, roundMoney(gross - order.Discount), order.Refunded, refundedTax}, nil
+}
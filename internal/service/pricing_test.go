@@ -0,0 +1,97 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-cofee/models"
+)
+
+func TestPriceOrder(t *testing.T) {
+	vat := models.TaxRate{ID: "vat", Name: "VAT", Rate: 10, Active: true}
+	includedVAT := models.TaxRate{ID: "vat", Name: "VAT", Rate: 10, Inclusive: true, Active: true}
+	tenOff := models.Promotion{ID: "ten", Name: "Ten off", Type: PromotionPercentage, Value: 10, Active: true}
+	items := func() []models.OrderItem {
+		return []models.OrderItem{{ProductID: "latte", Quantity: 2}, {ProductID: "muffin", Quantity: 1}}
+	}
+
+	tests := []struct {
+		name       string
+		taxes      []models.TaxRate
+		promotions []models.Promotion
+		original   *models.Order
+		prices     []float64
+		subtotal   float64
+		discount   float64
+		tax        float64
+		total      float64
+	}{
+		{name: "menu prices", prices: []float64{4, 3}, subtotal: 11, total: 11},
+		{name: "exclusive tax is added", taxes: []models.TaxRate{vat}, prices: []float64{4, 3}, subtotal: 11, tax: 1.1, total: 12.1},
+		{name: "inclusive tax is not added", taxes: []models.TaxRate{includedVAT}, prices: []float64{4, 3}, subtotal: 11, tax: 1, total: 11},
+		{name: "promotion discount is taxed after", taxes: []models.TaxRate{vat}, promotions: []models.Promotion{tenOff},
+			prices: []float64{4, 3}, subtotal: 11, discount: 1.1, tax: 0.99, total: 10.89},
+		{name: "modified order keeps its prices",
+			original: &models.Order{Items: []models.OrderItem{{ProductID: "latte", Quantity: 1, UnitPrice: 3.5}}},
+			prices:   []float64{3.5, 3}, subtotal: 10, total: 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useEmptyStorage(t)
+			writeStorage(t, "menu_items.json", testMenu)
+			writeStorage(t, "taxes.json", test.taxes)
+			writeStorage(t, "promotions.json", test.promotions)
+
+			order := models.Order{CustomerName: "Ann", CreatedAt: "2026-10-01 09:00:00", Items: items()}
+			if err := priceOrder(&order, test.original); err != nil {
+				t.Fatalf("priceOrder failed: %v", err)
+			}
+			var prices []float64
+			for _, item := range order.Items {
+				prices = append(prices, item.UnitPrice)
+			}
+			if !reflect.DeepEqual(prices, test.prices) {
+				t.Fatalf("got unit prices %v, expected %v", prices, test.prices)
+			}
+			got := []float64{order.Subtotal, order.Discount, order.TaxTotal, order.Total}
+			expected := []float64{test.subtotal, test.discount, test.tax, test.total}
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("got subtotal, discount, tax and total %v, expected %v", got, expected)
+			}
+		})
+	}
+}
+
+func TestOrderAmounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    models.Order
+		expected orderTotals
+	}{
+		{
+			name: "priced order",
+			order: models.Order{Subtotal: 11, Discount: 1.1, TaxTotal: 0.99, Total: 10.89, Refunded: 4.32,
+				Refunds: []models.Refund{{Amount: 3.96, Tax: 0.36}, {Amount: 0.36, Tax: 0.03}}},
+			expected: orderTotals{Gross: 11, Discount: 1.1, Tax: 0.99, Total: 10.89, Refunded: 4.32, RefundedTax: 0.39},
+		},
+		{
+			name:     "order saved before prices were kept is priced with the menu",
+			order:    models.Order{Items: []models.OrderItem{{ProductID: "latte", Quantity: 2}, {ProductID: "muffin", Quantity: 1}}, Discount: 1},
+			expected: orderTotals{Gross: 11, Discount: 1, Total: 10},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useEmptyStorage(t)
+			writeStorage(t, "menu_items.json", testMenu)
+
+			totals, err := orderAmounts(test.order)
+			if err != nil {
+				t.Fatalf("orderAmounts failed: %v", err)
+			}
+			if totals != test.expected {
+				t.Fatalf("got %+v, expected %+v", totals, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"hot-cofee/internal/dal"
+	"hot-cofee/models"
+)
+
+var (
+	ErrPromotionNotRead = newError(CodeStorage, "promotions were not read")
+	ErrInvalidPromoCode = newValidationError("promo_code", "promo code is not valid")
+)
+
+const (
+	PromotionPercentage = "percentage"
+	PromotionFixed      = "fixed"
+	PromotionBuyXGetY   = "buy_x_get_y"
+)
+
+type Promotion struct {
+	cachePromotions   []models.Promotion
+	takenIDPromotions map[string]int
+}
+
+// promotionsMu serializes the changes of promotions from loading to saving them
+var promotionsMu sync.Mutex
+
+type PromotionService interface {
+	LoadPromotionsCache() error
+	GetAllPromotions() ([]models.Promotion, error)
+	GetPromotionByID(id string) (models.Promotion, error)
+	AddNewPromotion(ctx context.Context, promotion models.Promotion) error
+	ModifyPromotion(ctx context.Context, promotion models.Promotion, version int) error
+	DeletePromotion(ctx context.Context, id string, version int) error
+	EvaluatePromotions(order *models.Order, at time.Time) error
+}
+
+func NewPromotionService() PromotionService {
+	return &Promotion{
+		cachePromotions:   []models.Promotion{},
+		takenIDPromotions: make(map[string]int),
+	}
+}
+
+// LoadPromotionsCache loads the promotions from the file to the cache
+func (p *Promotion) LoadPromotionsCache() error {
+	promotions, err := dal.NewPromotionRepository().ReadPromotions()
+	if err != nil {
+		return errors.Join(ErrPromotionNotRead, err)
+	}
+	p.cachePromotions = promotions
+	p.takenIDPromotions = make(map[string]int)
+	for i, val := range p.cachePromotions {
+		if err = validatePromotion(val); err != nil {
+			return errors.Join(ErrConflict, err)
+		}
+		if _, exists := p.takenIDPromotions[val.ID]; exists {
+			return ErrConflict
+		}
+		p.takenIDPromotions[val.ID] = i
+	}
+	return nil
+}
+
+// GetAllPromotions retrieves all promotions
+func (p *Promotion) GetAllPromotions() ([]models.Promotion, error) {
+	err := p.LoadPromotionsCache()
+	if err != nil {
+		return nil, err
+	}
+	if p.cachePromotions == nil {
+		return []models.Promotion{}, nil
+	}
+	return p.cachePromotions, nil
+}
+
+// GetPromotionByID retrieves a single promotion by ID
+func (p *Promotion) GetPromotionByID(id string) (models.Promotion, error) {
+	err := p.LoadPromotionsCache()
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	index, exists := p.takenIDPromotions[id]
+	if !exists || index < 0 || index >= len(p.cachePromotions) {
+		return models.Promotion{}, newNotFoundError("promotion with ID %s not found", id)
+	}
+	return p.cachePromotions[index], nil
+}
+
+// AddNewPromotion adds a new promotion and persists it
+func (p *Promotion) AddNewPromotion(ctx context.Context, promotion models.Promotion) error {
+	promotionsMu.Lock()
+	defer promotionsMu.Unlock()
+	err := p.LoadPromotionsCache()
+	if err != nil {
+		return err
+	}
+	if _, exists := p.takenIDPromotions[promotion.ID]; exists {
+		return ErrConflict
+	}
+	if err := validatePromotion(promotion); err != nil {
+		return err
+	}
+	if err := p.validatePromoCodeUnique(promotion); err != nil {
+		return err
+	}
+	promotion.Version = 1
+	p.cachePromotions = append(p.cachePromotions, promotion)
+	if err := dal.NewPromotionRepository().WritePromotions(p.cachePromotions); err != nil {
+		return newError(CodeStorage, "failed to save promotion")
+	}
+	recordChange(ctx, "create", "promotion", promotion.ID, nil, promotion)
+	return nil
+}
+
+// ModifyPromotion replaces an existing promotion if it still has the given version or any is given
+func (p *Promotion) ModifyPromotion(ctx context.Context, promotion models.Promotion, version int) error {
+	promotionsMu.Lock()
+	defer promotionsMu.Unlock()
+	err := p.LoadPromotionsCache()
+	if err != nil {
+		return err
+	}
+	index, exists := p.takenIDPromotions[promotion.ID]
+	if !exists || index < 0 || index >= len(p.cachePromotions) {
+		return newNotFoundError("promotion with ID %s not found", promotion.ID)
+	}
+	if err := checkVersion(version, p.cachePromotions[index].Version); err != nil {
+		return err
+	}
+	if err := validatePromotion(promotion); err != nil {
+		return err
+	}
+	if err := p.validatePromoCodeUnique(promotion); err != nil {
+		return err
+	}
+	before := p.cachePromotions[index]
+	promotion.Version = before.Version + 1
+	p.cachePromotions[index] = promotion
+	if err := dal.NewPromotionRepository().WritePromotions(p.cachePromotions); err != nil {
+		return newError(CodeStorage, "failed to modify promotion")
+	}
+	recordChange(ctx, "update", "promotion", promotion.ID, before, promotion)
+	return nil
+}
+
+// DeletePromotion deletes a promotion by ID. Orders keep the promotions already applied to them.
+func (p *Promotion) DeletePromotion(ctx context.Context, id string, version int) error {
+	promotionsMu.Lock()
+	defer promotionsMu.Unlock()
+	err := p.LoadPromotionsCache()
+	if err != nil {
+		return err
+	}
+	index, exists := p.takenIDPromotions[id]
+	if !exists || index < 0 || index >= len(p.cachePromotions) {
+		return newNotFoundError("promotion with ID %s not found", id)
+	}
+	if err := checkVersion(version, p.cachePromotions[index].Version); err != nil {
+		return err
+	}
+	before := p.cachePromotions[index]
+	p.cachePromotions = append(p.cachePromotions[:index], p.cachePromotions[index+1:]...)
+	if err := dal.NewPromotionRepository().WritePromotions(p.cachePromotions); err != nil {
+		return err
+	}
+	recordChange(ctx, "delete", "promotion", id, before, nil)
+	return nil
+}
+
+// EvaluatePromotions applies every active promotion valid at the given time to the
+// priced order lines and records them on the order. The order subtotal must already be computed.
+func (p *Promotion) EvaluatePromotions(order *models.Order, at time.Time) error {
+	order.Promotions = nil
+	err := p.LoadPromotionsCache()
+	if err != nil {
+		return err
+	}
+	if order.PromoCode != "" && !p.promoCodeExists(order.PromoCode, at) {
+		return fmt.Errorf("%w: %s", ErrInvalidPromoCode, order.PromoCode)
+	}
+	menu, err := NewMenuService().GetAllMenu()
+	if err != nil {
+		return err
+	}
+	categories := make(map[string]string)
+	for _, item := range menu {
+		categories[item.ID] = item.Category
+	}
+
+	remaining := order.Subtotal
+	for _, promotion := range p.cachePromotions {
+		if !promotion.Active || !promotionInWindow(promotion, at) {
+			continue
+		}
+		if promotion.Code != "" && !strings.EqualFold(promotion.Code, order.PromoCode) {
+			continue
+		}
+		discount := 0.0
+		eligibleSubtotal := 0.0
+		for _, item := range order.Items {
+			if !promotionCovers(promotion, item.ProductID, categories[item.ProductID]) {
+				continue
+			}
+			lineTotal := float64(item.Quantity) * item.UnitPrice
+			eligibleSubtotal += lineTotal
+			if promotion.Type == PromotionBuyXGetY {
+				freeUnits := item.Quantity / (promotion.BuyQuantity + promotion.GetQuantity) * promotion.GetQuantity
+				discount += float64(freeUnits) * item.UnitPrice
+			}
+		}
+		switch promotion.Type {
+		case PromotionPercentage:
+			discount = eligibleSubtotal * promotion.Value / 100
+		case PromotionFixed:
+			if eligibleSubtotal > 0 {
+				discount = promotion.Value
+			}
+		}
+		discount = math.Min(roundMoney(discount), roundMoney(remaining))
+		if discount <= 0 {
+			continue
+		}
+		remaining -= discount
+		order.Promotions = append(order.Promotions, models.AppliedPromotion{
+			PromotionID: promotion.ID,
+			Name:        promotion.Name,
+			Discount:    discount,
+		})
+	}
+	return nil
+}
+
+func (p *Promotion) promoCodeExists(code string, at time.Time) bool {
+	for _, promotion := range p.cachePromotions {
+		if promotion.Active && promotion.Code != "" && strings.EqualFold(promotion.Code, code) && promotionInWindow(promotion, at) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Promotion) validatePromoCodeUnique(promotion models.Promotion) error {
+	if promotion.Code == "" {
+		return nil
+	}
+	for _, val := range p.cachePromotions {
+		if val.ID != promotion.ID && strings.EqualFold(val.Code, promotion.Code) {
+			return errors.Join(ErrConflict, fmt.Errorf("promo code %s is used by promotion %s", promotion.Code, val.ID))
+		}
+	}
+	return nil
+}
+
+// promotionCovers reports whether a line with the given product and category is eligible.
+// A promotion without product and category limits covers the whole order.
+func promotionCovers(promotion models.Promotion, productID, category string) bool {
+	if len(promotion.ProductIDs) == 0 && len(promotion.Categories) == 0 {
+		return true
+	}
+	if containsString(promotion.ProductIDs, productID) {
+		return true
+	}
+	for _, val := range promotion.Categories {
+		if category != "" && strings.EqualFold(val, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// promotionInWindow checks the days and the time of day of a promotion.
+// A window whose end is before its start runs over midnight.
+func promotionInWindow(promotion models.Promotion, at time.Time) bool {
+	if len(promotion.Days) > 0 {
+		day := strings.ToLower(at.Weekday().String()[:3])
+		found := false
+		for _, val := range promotion.Days {
+			if strings.EqualFold(val, day) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if promotion.StartTime == "" || promotion.EndTime == "" {
+		return true
+	}
+	now := at.Format("15:04")
+	if promotion.StartTime <= promotion.EndTime {
+		return now >= promotion.StartTime && now < promotion.EndTime
+	}
+	return now >= promotion.StartTime || now < promotion.EndTime
+}
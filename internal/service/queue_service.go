@@ -0,0 +1,152 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"hot-cofee/models"
+)
+
+const (
+	OrderOpen   = "Open"
+	OrderReady  = "Ready"
+	OrderClosed = "Closed"
+
+	ItemQueued     = "queued"
+	ItemInProgress = "in_progress"
+	ItemDone       = "done"
+
+	// DefaultPrepTime is assumed for menu items without a preparation time
+	DefaultPrepTime = 2 * time.Minute
+)
+
+// GetQueue lists the open and ready orders first in, first out, as the baristas should
+// prepare and hand them out
+func GetQueue() ([]models.QueueEntry, error) {
+	orders, err := NewOrderService().GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+	menu, err := NewMenuService().GetAllMenu()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, item := range menu {
+		names[item.ID] = item.Name
+	}
+	estimates := estimateReadyTimes(orders, menu, time.Now())
+
+	queue := []models.QueueEntry{}
+	for _, order := range queuedOrders(orders) {
+		entry := models.QueueEntry{
+			Position:         len(queue) + 1,
+			OrderID:          order.ID,
+			CustomerName:     order.CustomerName,
+			Status:           order.Status,
+			CreatedAt:        order.CreatedAt,
+			EstimatedReadyAt: estimates[order.ID],
+		}
+		for _, item := range order.Items {
+			entry.Items = append(entry.Items, models.QueueItem{
+				ProductID: item.ProductID,
+				Name:      names[item.ProductID],
+				Quantity:  item.Quantity,
+				Modifiers: item.Modifiers,
+				Status:    itemStatus(item),
+			})
+		}
+		queue = append(queue, entry)
+	}
+	return queue, nil
+}
+
+// EstimateReadyTimes returns the estimated ready time of every open order by ID
+func EstimateReadyTimes() (map[int]string, error) {
+	orders, err := NewOrderService().GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+	menu, err := NewMenuService().GetAllMenu()
+	if err != nil {
+		return nil, err
+	}
+	return estimateReadyTimes(orders, menu, time.Now()), nil
+}
+
+// estimateReadyTimes works through the queue as one barista would: every line that is not
+// done takes its quantity times the preparation time of the product, a line in progress
+// half of that. An order is ready when all lines before and including its own are made.
+func estimateReadyTimes(orders []models.Order, menu []models.MenuItem, now time.Time) map[int]string {
+	prepTimes := make(map[string]time.Duration)
+	for _, item := range menu {
+		if item.PrepSeconds > 0 {
+			prepTimes[item.ID] = time.Duration(item.PrepSeconds) * time.Second
+		}
+	}
+	estimates := make(map[int]string)
+	readyAt := now
+	for _, order := range queuedOrders(orders) {
+		if order.Status != OrderOpen {
+			continue
+		}
+		for _, item := range order.Items {
+			prepTime, exists := prepTimes[item.ProductID]
+			if !exists {
+				prepTime = DefaultPrepTime
+			}
+			switch itemStatus(item) {
+			case ItemQueued:
+				readyAt = readyAt.Add(time.Duration(item.Quantity) * prepTime)
+			case ItemInProgress:
+				readyAt = readyAt.Add(time.Duration(item.Quantity) * prepTime / 2)
+			}
+		}
+		estimates[order.ID] = readyAt.Format(time.DateTime)
+	}
+	return estimates
+}
+
+// queuedOrders returns the open and ready orders, first in first out
+func queuedOrders(orders []models.Order) []models.Order {
+	var queued []models.Order
+	for _, order := range orders {
+		if order.Status == OrderOpen || order.Status == OrderReady {
+			queued = append(queued, order)
+		}
+	}
+	sort.SliceStable(queued, func(i, j int) bool {
+		if queued[i].CreatedAt != queued[j].CreatedAt {
+			return queued[i].CreatedAt < queued[j].CreatedAt
+		}
+		return queued[i].ID < queued[j].ID
+	})
+	return queued
+}
+
+// itemStatus treats lines stored before they had a status as queued
+func itemStatus(item models.OrderItem) string {
+	if item.Status == "" {
+		return ItemQueued
+	}
+	return item.Status
+}
+
+// updateReadyStatus moves an open order to Ready once every line is done,
+// and back to Open when a line is not done anymore
+func updateReadyStatus(order *models.Order) {
+	if order.Status == OrderClosed {
+		return
+	}
+	allDone := len(order.Items) > 0
+	for _, item := range order.Items {
+		if itemStatus(item) != ItemDone {
+			allDone = false
+		}
+	}
+	if allDone {
+		order.Status = OrderReady
+	} else {
+		order.Status = OrderOpen
+	}
+}
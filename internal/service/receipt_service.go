@@ -0,0 +1,260 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+
+	"hot-cofee/internal/config"
+	"hot-cofee/models"
+)
+
+var ErrUnsupportedReceiptFormat = newValidationError("format", "unsupported receipt format (should be txt, html or escpos)")
+
+const (
+	ReceiptText   = "txt"
+	ReceiptHTML   = "html"
+	ReceiptESCPOS = "escpos"
+
+	// receiptWidth is the number of characters per line of a 80mm thermal printer in font A
+	receiptWidth = 42
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// ESC/POS control sequences
+const (
+	escInit        = "\x1b@"
+	escCodePage437 = "\x1bt\x00"
+	escBoldOn      = "\x1bE\x01"
+	escBoldOff     = "\x1bE\x00"
+	escAlignCenter = "\x1ba\x01"
+	escAlignLeft   = "\x1ba\x00"
+	escFeedLines   = "\x1bd\x04"
+	escPartialCut  = "\x1dVB\x00"
+)
+
+type receiptLine struct {
+	Name      string
+	Quantity  int
+	UnitPrice float64
+	Total     float64
+	Modifiers []string
+}
+
+type receiptAmount struct {
+	Name   string
+	Amount float64
+}
+
+type receiptData struct {
+	Shop         config.ShopInfo
+	OrderID      int
+	CustomerName string
+	CreatedAt    string
+	Lines        []receiptLine
+	Subtotal     float64
+	Discounts    []receiptAmount
+	TaxLines     []models.TaxLine
+	Total        float64
+	Payments     []models.Payment
+	Refunded     float64
+	PrintedAt    string
+}
+
+// RenderReceipt renders the receipt of an order as plain text, HTML or an ESC/POS byte
+// stream and returns it with its content type. Templates named receipt.txt.tmpl and
+// receipt.html.tmpl in the configured templates directory replace the built-in ones;
+// the ESC/POS receipt is printed from the text template.
+func RenderReceipt(ID int, format string) ([]byte, string, error) {
+	if format == "" {
+		format = ReceiptText
+	}
+	if format != ReceiptText && format != ReceiptHTML && format != ReceiptESCPOS {
+		return nil, "", ErrUnsupportedReceiptFormat
+	}
+	order, err := NewOrderService().GetOrderByID(ID)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := newReceiptData(order)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case ReceiptHTML:
+		source, err := loadReceiptTemplate("receipt.html.tmpl")
+		if err != nil {
+			return nil, "", err
+		}
+		tmpl, err := htmltemplate.New("receipt").Funcs(receiptFuncs(format)).Parse(source)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid receipt template: %w", err)
+		}
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "text/html; charset=utf-8", nil
+	default:
+		source, err := loadReceiptTemplate("receipt.txt.tmpl")
+		if err != nil {
+			return nil, "", err
+		}
+		tmpl, err := texttemplate.New("receipt").Funcs(receiptFuncs(format)).Parse(source)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid receipt template: %w", err)
+		}
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, "", err
+		}
+		if format == ReceiptESCPOS {
+			return toESCPOS(buf.String()), "application/octet-stream", nil
+		}
+		return buf.Bytes(), "text/plain; charset=utf-8", nil
+	}
+}
+
+func newReceiptData(order models.Order) (receiptData, error) {
+	m := NewMenuService()
+	totals, err := orderAmounts(order)
+	if err != nil {
+		return receiptData{}, err
+	}
+	data := receiptData{
+		Shop:         config.GetShopInfo(),
+		OrderID:      order.ID,
+		CustomerName: order.CustomerName,
+		CreatedAt:    order.CreatedAt,
+		Subtotal:     totals.Gross,
+		TaxLines:     order.TaxLines,
+		Total:        totals.Total,
+		Payments:     order.Payments,
+		Refunded:     order.Refunded,
+		PrintedAt:    time.Now().Format(time.DateTime),
+	}
+	for _, item := range order.Items {
+		line := receiptLine{
+			Name:      item.ProductID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			Modifiers: item.Modifiers,
+		}
+		// The receipt shows the name from the menu but always the price the line was sold at
+		if product, err := m.GetMenuByID(item.ProductID); err == nil {
+			line.Name = product.Name
+			if line.UnitPrice == 0 {
+				line.UnitPrice = product.Price
+			}
+		}
+		line.Total = roundMoney(float64(line.Quantity) * line.UnitPrice)
+		data.Lines = append(data.Lines, line)
+	}
+	for _, promotion := range order.Promotions {
+		data.Discounts = append(data.Discounts, receiptAmount{promotion.Name, promotion.Discount})
+	}
+	for _, reward := range order.Rewards {
+		data.Discounts = append(data.Discounts, receiptAmount{"Reward " + reward.RewardID, reward.Discount})
+	}
+	return data, nil
+}
+
+func loadReceiptTemplate(name string) (string, error) {
+	if dir := config.GetTemplatesPath(); dir != "" {
+		source, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(source), nil
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("unable to read receipt template: %w", err)
+		}
+	}
+	source, err := defaultTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", err
+	}
+	return string(source), nil
+}
+
+// receiptFuncs returns the template functions. Layout helpers pad to the receipt width
+// for text, and emit ESC/POS alignment and emphasis commands for thermal printers.
+func receiptFuncs(format string) map[string]any {
+	funcs := map[string]any{
+		"money": func(amount float64) string {
+			return fmt.Sprintf("%.2f", amount)
+		},
+		"title": func(s string) string {
+			if s == "" {
+				return s
+			}
+			return strings.ToUpper(s[:1]) + s[1:]
+		},
+		"ternary": func(condition bool, yes, no string) string {
+			if condition {
+				return yes
+			}
+			return no
+		},
+		"line": func() string {
+			return strings.Repeat("-", receiptWidth)
+		},
+		"row": func(left, right string) string {
+			gap := receiptWidth - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+			if gap < 1 {
+				gap = 1
+			}
+			return left + strings.Repeat(" ", gap) + right
+		},
+		"center": func(s string) string {
+			gap := (receiptWidth - utf8.RuneCountInString(s)) / 2
+			if gap < 0 {
+				gap = 0
+			}
+			return strings.Repeat(" ", gap) + s
+		},
+		"bold": func(s string) string {
+			return s
+		},
+	}
+	if format == ReceiptESCPOS {
+		funcs["center"] = func(s string) string {
+			return escAlignCenter + s + escAlignLeft
+		}
+		funcs["bold"] = func(s string) string {
+			return escBoldOn + s + escBoldOff
+		}
+	}
+	return funcs
+}
+
+// toESCPOS wraps the rendered text into a printer job: reset, code page 437,
+// the text with anything outside ASCII replaced, paper feed and a partial cut
+func toESCPOS(text string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(escInit)
+	buf.WriteString(escCodePage437)
+	for _, r := range text {
+		switch {
+		case r == '\n':
+			buf.WriteByte('\n')
+		case r < 0x80:
+			buf.WriteByte(byte(r))
+		default:
+			buf.WriteByte('?')
+		}
+	}
+	if !strings.HasSuffix(text, "\n") {
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(escFeedLines)
+	buf.WriteString(escPartialCut)
+	return buf.Bytes()
+}
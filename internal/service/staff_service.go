@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"hot-cofee/internal/dal"
+	"hot-cofee/models"
+)
+
+var (
+	ErrStaffNotRead   = newError(CodeStorage, "staff were not read")
+	ErrShiftNotRead   = newError(CodeStorage, "shifts were not read")
+	ErrStaffHasShifts = newError(CodeConflict, "staff member has shifts, set active to false instead")
+)
+
+type Staff struct {
+	cacheStaff   []models.Staff
+	takenIDStaff map[int]int
+}
+
+// staffMu serializes the changes of staff and their shifts from loading to saving them
+var staffMu sync.Mutex
+
+type StaffService interface {
+	LoadStaffCache() error
+	GetAllStaff() ([]models.Staff, error)
+	GetStaffByID(ID int) (models.Staff, error)
+	AddNewStaff(ctx context.Context, staff models.Staff) (models.Staff, error)
+	ModifyStaff(ctx context.Context, staff models.Staff) error
+	DeleteStaff(ctx context.Context, ID int) error
+	ClockIn(ctx context.Context, ID int) (models.Shift, error)
+	ClockOut(ctx context.Context, ID int) (models.Shift, error)
+	GetShifts(staffID int) ([]models.Shift, error)
+	GetShiftByID(ID int) (models.Shift, error)
+}
+
+func NewStaffService() StaffService {
+	return &Staff{
+		cacheStaff:   []models.Staff{},
+		takenIDStaff: make(map[int]int),
+	}
+}
+
+// LoadStaffCache loads the staff from the file to the cache
+func (s *Staff) LoadStaffCache() error {
+	staff, err := dal.NewStaffRepository().ReadStaff()
+	if err != nil {
+		return errors.Join(ErrStaffNotRead, err)
+	}
+	s.cacheStaff = staff
+	s.takenIDStaff = make(map[int]int)
+	for i, val := range s.cacheStaff {
+		if err = validateStaff(val); err != nil {
+			return errors.Join(ErrConflict, err)
+		}
+		if _, exists := s.takenIDStaff[val.ID]; exists {
+			return ErrConflict
+		}
+		s.takenIDStaff[val.ID] = i
+	}
+	return nil
+}
+
+// GetAllStaff retrieves all members of staff
+func (s *Staff) GetAllStaff() ([]models.Staff, error) {
+	err := s.LoadStaffCache()
+	if err != nil {
+		return nil, err
+	}
+	if s.cacheStaff == nil {
+		return []models.Staff{}, nil
+	}
+	return s.cacheStaff, nil
+}
+
+// GetStaffByID retrieves a single member of staff by ID
+func (s *Staff) GetStaffByID(ID int) (models.Staff, error) {
+	err := s.LoadStaffCache()
+	if err != nil {
+		return models.Staff{}, err
+	}
+	index, exists := s.takenIDStaff[ID]
+	if !exists || index < 0 || index >= len(s.cacheStaff) {
+		return models.Staff{}, newNotFoundError("staff member with ID %d not found", ID)
+	}
+	return s.cacheStaff[index], nil
+}
+
+// AddNewStaff assigns the next free ID to a new, active member of staff and persists it
+func (s *Staff) AddNewStaff(ctx context.Context, staff models.Staff) (models.Staff, error) {
+	staffMu.Lock()
+	defer staffMu.Unlock()
+	err := s.LoadStaffCache()
+	if err != nil {
+		return models.Staff{}, err
+	}
+	// Staff IDs start at 1 so that 0 can mean "nobody" on orders and inventory items
+	staff.ID = 1
+	for _, val := range s.cacheStaff {
+		if val.ID >= staff.ID {
+			staff.ID = val.ID + 1
+		}
+	}
+	staff.Active = true
+	staff.CreatedAt = time.Now().Format(time.DateTime)
+	if err := validateStaff(staff); err != nil {
+		return models.Staff{}, err
+	}
+	s.cacheStaff = append(s.cacheStaff, staff)
+	if err := dal.NewStaffRepository().WriteStaff(s.cacheStaff); err != nil {
+		return models.Staff{}, newError(CodeStorage, "failed to save staff member")
+	}
+	recordChange(ctx, "create", "staff", strconv.Itoa(staff.ID), nil, staff)
+	return staff, nil
+}
+
+// ModifyStaff replaces the record of an existing member of staff
+func (s *Staff) ModifyStaff(ctx context.Context, staff models.Staff) error {
+	staffMu.Lock()
+	defer staffMu.Unlock()
+	err := s.LoadStaffCache()
+	if err != nil {
+		return err
+	}
+	index, exists := s.takenIDStaff[staff.ID]
+	if !exists || index < 0 || index >= len(s.cacheStaff) {
+		return newNotFoundError("staff member with ID %d not found", staff.ID)
+	}
+	staff.CreatedAt = s.cacheStaff[index].CreatedAt
+	if err := validateStaff(staff); err != nil {
+		return err
+	}
+	if s.cacheStaff[index] == staff {
+		return ErrNothingToModify
+	}
+	before := s.cacheStaff[index]
+	s.cacheStaff[index] = staff
+	if err := dal.NewStaffRepository().WriteStaff(s.cacheStaff); err != nil {
+		return newError(CodeStorage, "failed to modify staff member")
+	}
+	recordChange(ctx, "update", "staff", strconv.Itoa(staff.ID), before, staff)
+	return nil
+}
+
+// DeleteStaff removes a member of staff that never worked a shift. Others are kept for the
+// reports and set inactive instead.
+func (s *Staff) DeleteStaff(ctx context.Context, ID int) error {
+	staffMu.Lock()
+	defer staffMu.Unlock()
+	err := s.LoadStaffCache()
+	if err != nil {
+		return err
+	}
+	index, exists := s.takenIDStaff[ID]
+	if !exists || index < 0 || index >= len(s.cacheStaff) {
+		return newNotFoundError("staff member with ID %d not found", ID)
+	}
+	shifts, err := s.GetShifts(ID)
+	if err != nil {
+		return err
+	}
+	if len(shifts) > 0 {
+		return fmt.Errorf("%w: %d shifts of staff member %d", ErrStaffHasShifts, len(shifts), ID)
+	}
+	before := s.cacheStaff[index]
+	s.cacheStaff = append(s.cacheStaff[:index], s.cacheStaff[index+1:]...)
+	if err := dal.NewStaffRepository().WriteStaff(s.cacheStaff); err != nil {
+		return newError(CodeStorage, "failed to delete staff member")
+	}
+	recordChange(ctx, "delete", "staff", strconv.Itoa(ID), before, nil)
+	return nil
+}
+
+// ClockIn opens a shift for an active member of staff that is not clocked in yet
+func (s *Staff) ClockIn(ctx context.Context, ID int) (models.Shift, error) {
+	staffMu.Lock()
+	defer staffMu.Unlock()
+	staff, err := s.GetStaffByID(ID)
+	if err != nil {
+		return models.Shift{}, err
+	}
+	if !staff.Active {
+		return models.Shift{}, newError(CodeConflict, "staff member %d is not active", ID)
+	}
+	shifts, err := readShifts()
+	if err != nil {
+		return models.Shift{}, err
+	}
+	shift := models.Shift{ID: 1, StaffID: ID, ClockIn: time.Now().Format(time.DateTime)}
+	for _, val := range shifts {
+		if val.StaffID == ID && val.ClockOut == "" {
+			return models.Shift{}, newError(CodeConflict, "staff member %d is already clocked in since %s", ID, val.ClockIn)
+		}
+		if val.ID >= shift.ID {
+			shift.ID = val.ID + 1
+		}
+	}
+	if err := dal.NewStaffRepository().WriteShifts(append(shifts, shift)); err != nil {
+		return models.Shift{}, newError(CodeStorage, "failed to clock in")
+	}
+	recordChange(ctx, "clock_in", "staff", strconv.Itoa(ID), nil, shift)
+	return shift, nil
+}
+
+// ClockOut closes the open shift of a member of staff
+func (s *Staff) ClockOut(ctx context.Context, ID int) (models.Shift, error) {
+	staffMu.Lock()
+	defer staffMu.Unlock()
+	if _, err := s.GetStaffByID(ID); err != nil {
+		return models.Shift{}, err
+	}
+	shifts, err := readShifts()
+	if err != nil {
+		return models.Shift{}, err
+	}
+	for i, val := range shifts {
+		if val.StaffID != ID || val.ClockOut != "" {
+			continue
+		}
+		shifts[i].ClockOut = time.Now().Format(time.DateTime)
+		if err := dal.NewStaffRepository().WriteShifts(shifts); err != nil {
+			return models.Shift{}, newError(CodeStorage, "failed to clock out")
+		}
+		recordChange(ctx, "clock_out", "staff", strconv.Itoa(ID), val, shifts[i])
+		return shifts[i], nil
+	}
+	return models.Shift{}, newError(CodeConflict, "staff member %d is not clocked in", ID)
+}
+
+// GetShifts retrieves the shifts of a member of staff, or of everybody for staff ID 0
+func (s *Staff) GetShifts(staffID int) ([]models.Shift, error) {
+	shifts, err := readShifts()
+	if err != nil {
+		return nil, err
+	}
+	result := []models.Shift{}
+	for _, val := range shifts {
+		if staffID == 0 || val.StaffID == staffID {
+			result = append(result, val)
+		}
+	}
+	return result, nil
+}
+
+// GetShiftByID retrieves a single shift by ID
+func (s *Staff) GetShiftByID(ID int) (models.Shift, error) {
+	shifts, err := readShifts()
+	if err != nil {
+		return models.Shift{}, err
+	}
+	for _, val := range shifts {
+		if val.ID == ID {
+			return val, nil
+		}
+	}
+	return models.Shift{}, newNotFoundError("shift with ID %d not found", ID)
+}
+
+// openShiftID returns the ID of the shift a member of staff is clocked in to, or 0
+func openShiftID(staffID int) (int, error) {
+	if staffID == 0 {
+		return 0, nil
+	}
+	shifts, err := readShifts()
+	if err != nil {
+		return 0, err
+	}
+	for _, val := range shifts {
+		if val.StaffID == staffID && val.ClockOut == "" {
+			return val.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+func readShifts() ([]models.Shift, error) {
+	shifts, err := dal.NewStaffRepository().ReadShifts()
+	if err != nil {
+		return nil, errors.Join(ErrShiftNotRead, err)
+	}
+	return shifts, nil
+}
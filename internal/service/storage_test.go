@@ -0,0 +1,47 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"hot-cofee/models"
+)
+
+// useEmptyStorage runs the test in an empty directory, which is where the storage is when no
+// configuration is loaded
+func useEmptyStorage(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// writeStorage saves value as the named file of the storage used by the test
+func writeStorage(t *testing.T, name string, value any) {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testMenu is a small menu with a drink and a food item for the pricing tests
+var testMenu = []models.MenuItem{
+	{ID: "latte", Name: "Latte", Description: "Espresso with milk", Category: "drinks", Price: 4, Version: 1,
+		Ingredients: []models.MenuItemIngredient{{IngredientID: "milk", Quantity: 200}, {IngredientID: "espresso_shot", Quantity: 1}}},
+	{ID: "muffin", Name: "Muffin", Description: "Blueberry muffin", Category: "food", Price: 3, Version: 1,
+		Ingredients: []models.MenuItemIngredient{{IngredientID: "flour", Quantity: 100}}},
+}
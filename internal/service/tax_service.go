@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"hot-cofee/internal/dal"
+	"hot-cofee/models"
+)
+
+var ErrTaxNotRead = newError(CodeStorage, "taxes were not read")
+
+type Tax struct {
+	cacheTaxes   []models.TaxRate
+	takenIDTaxes map[string]int
+}
+
+// taxesMu serializes the changes of tax rates from loading to saving them
+var taxesMu sync.Mutex
+
+type TaxService interface {
+	LoadTaxesCache() error
+	GetAllTaxes() ([]models.TaxRate, error)
+	GetTaxByID(id string) (models.TaxRate, error)
+	AddNewTax(ctx context.Context, tax models.TaxRate) error
+	ModifyTax(ctx context.Context, tax models.TaxRate, version int) error
+	DeleteTax(ctx context.Context, id string, version int) error
+	ApplyTaxes(order *models.Order) error
+}
+
+func NewTaxService() TaxService {
+	return &Tax{
+		cacheTaxes:   []models.TaxRate{},
+		takenIDTaxes: make(map[string]int),
+	}
+}
+
+// LoadTaxesCache loads the tax rates from the file to the cache
+func (t *Tax) LoadTaxesCache() error {
+	taxes, err := dal.NewTaxRepository().ReadTaxes()
+	if err != nil {
+		return errors.Join(ErrTaxNotRead, err)
+	}
+	t.cacheTaxes = taxes
+	t.takenIDTaxes = make(map[string]int)
+	for i, val := range t.cacheTaxes {
+		if err = validateTax(val); err != nil {
+			return errors.Join(ErrConflict, err)
+		}
+		if _, exists := t.takenIDTaxes[val.ID]; exists {
+			return ErrConflict
+		}
+		t.takenIDTaxes[val.ID] = i
+	}
+	return nil
+}
+
+// GetAllTaxes retrieves all tax rates
+func (t *Tax) GetAllTaxes() ([]models.TaxRate, error) {
+	err := t.LoadTaxesCache()
+	if err != nil {
+		return nil, err
+	}
+	if t.cacheTaxes == nil {
+		return []models.TaxRate{}, nil
+	}
+	return t.cacheTaxes, nil
+}
+
+// GetTaxByID retrieves a single tax rate by ID
+func (t *Tax) GetTaxByID(id string) (models.TaxRate, error) {
+	err := t.LoadTaxesCache()
+	if err != nil {
+		return models.TaxRate{}, err
+	}
+	index, exists := t.takenIDTaxes[id]
+	if !exists || index < 0 || index >= len(t.cacheTaxes) {
+		return models.TaxRate{}, newNotFoundError("tax with ID %s not found", id)
+	}
+	return t.cacheTaxes[index], nil
+}
+
+// AddNewTax adds a new tax rate and persists it
+func (t *Tax) AddNewTax(ctx context.Context, tax models.TaxRate) error {
+	taxesMu.Lock()
+	defer taxesMu.Unlock()
+	err := t.LoadTaxesCache()
+	if err != nil {
+		return err
+	}
+	if _, exists := t.takenIDTaxes[tax.ID]; exists {
+		return ErrConflict
+	}
+	if err := validateTax(tax); err != nil {
+		return err
+	}
+	tax.Version = 1
+	t.cacheTaxes = append(t.cacheTaxes, tax)
+	if err := dal.NewTaxRepository().WriteTaxes(t.cacheTaxes); err != nil {
+		return newError(CodeStorage, "failed to save tax")
+	}
+	recordChange(ctx, "create", "tax", tax.ID, nil, tax)
+	return nil
+}
+
+// ModifyTax replaces an existing tax rate if it still has the given version or any is given.
+// Orders keep the tax lines they were priced with.
+func (t *Tax) ModifyTax(ctx context.Context, tax models.TaxRate, version int) error {
+	taxesMu.Lock()
+	defer taxesMu.Unlock()
+	err := t.LoadTaxesCache()
+	if err != nil {
+		return err
+	}
+	index, exists := t.takenIDTaxes[tax.ID]
+	if !exists || index < 0 || index >= len(t.cacheTaxes) {
+		return newNotFoundError("tax with ID %s not found", tax.ID)
+	}
+	if err := checkVersion(version, t.cacheTaxes[index].Version); err != nil {
+		return err
+	}
+	if err := validateTax(tax); err != nil {
+		return err
+	}
+	before := t.cacheTaxes[index]
+	tax.Version = before.Version + 1
+	t.cacheTaxes[index] = tax
+	if err := dal.NewTaxRepository().WriteTaxes(t.cacheTaxes); err != nil {
+		return newError(CodeStorage, "failed to modify tax")
+	}
+	recordChange(ctx, "update", "tax", tax.ID, before, tax)
+	return nil
+}
+
+// DeleteTax deletes a tax rate by ID
+func (t *Tax) DeleteTax(ctx context.Context, id string, version int) error {
+	taxesMu.Lock()
+	defer taxesMu.Unlock()
+	err := t.LoadTaxesCache()
+	if err != nil {
+		return err
+	}
+	index, exists := t.takenIDTaxes[id]
+	if !exists || index < 0 || index >= len(t.cacheTaxes) {
+		return newNotFoundError("tax with ID %s not found", id)
+	}
+	if err := checkVersion(version, t.cacheTaxes[index].Version); err != nil {
+		return err
+	}
+	before := t.cacheTaxes[index]
+	t.cacheTaxes = append(t.cacheTaxes[:index], t.cacheTaxes[index+1:]...)
+	if err := dal.NewTaxRepository().WriteTaxes(t.cacheTaxes); err != nil {
+		return err
+	}
+	recordChange(ctx, "delete", "tax", id, before, nil)
+	return nil
+}
+
+// ApplyTaxes computes the tax lines of a priced order. The order discount is spread over
+// the lines in proportion to their amount, and every line is taxed with the most specific
+// active rate: one naming the product, then one naming its category, then a default rate.
+func (t *Tax) ApplyTaxes(order *models.Order) error {
+	order.TaxLines = nil
+	order.TaxTotal = 0
+	err := t.LoadTaxesCache()
+	if err != nil {
+		return err
+	}
+	if len(t.cacheTaxes) == 0 || order.Subtotal <= 0 {
+		return nil
+	}
+	menu, err := NewMenuService().GetAllMenu()
+	if err != nil {
+		return err
+	}
+	categories := make(map[string]string)
+	for _, item := range menu {
+		categories[item.ID] = item.Category
+	}
+
+	discountRatio := order.Discount / order.Subtotal
+	indexByTax := make(map[string]int)
+	for _, item := range order.Items {
+		tax, found := t.rateFor(item.ProductID, categories[item.ProductID])
+		if !found {
+			continue
+		}
+		taxable := float64(item.Quantity) * item.UnitPrice * (1 - discountRatio)
+		index, exists := indexByTax[tax.ID]
+		if !exists {
+			index = len(order.TaxLines)
+			indexByTax[tax.ID] = index
+			order.TaxLines = append(order.TaxLines, models.TaxLine{
+				TaxID:     tax.ID,
+				Name:      tax.Name,
+				Rate:      tax.Rate,
+				Inclusive: tax.Inclusive,
+			})
+		}
+		order.TaxLines[index].Taxable += taxable
+	}
+
+	for i, line := range order.TaxLines {
+		if line.Inclusive {
+			line.Amount = line.Taxable * line.Rate / (100 + line.Rate)
+		} else {
+			line.Amount = line.Taxable * line.Rate / 100
+		}
+		line.Taxable = roundMoney(line.Taxable)
+		line.Amount = roundMoney(line.Amount)
+		order.TaxLines[i] = line
+		order.TaxTotal += line.Amount
+	}
+	order.TaxTotal = roundMoney(order.TaxTotal)
+	return nil
+}
+
+func (t *Tax) rateFor(productID, category string) (models.TaxRate, bool) {
+	var byCategory, byDefault *models.TaxRate
+	for i, tax := range t.cacheTaxes {
+		if !tax.Active {
+			continue
+		}
+		if containsString(tax.ProductIDs, productID) {
+			return tax, true
+		}
+		for _, val := range tax.Categories {
+			if byCategory == nil && category != "" && strings.EqualFold(val, category) {
+				byCategory = &t.cacheTaxes[i]
+			}
+		}
+		if byDefault == nil && len(tax.ProductIDs) == 0 && len(tax.Categories) == 0 {
+			byDefault = &t.cacheTaxes[i]
+		}
+	}
+	if byCategory != nil {
+		return *byCategory, true
+	}
+	if byDefault != nil {
+		return *byDefault, true
+	}
+	return models.TaxRate{}, false
+}
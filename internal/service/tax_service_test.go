@@ -0,0 +1,65 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-cofee/models"
+)
+
+func TestApplyTaxes(t *testing.T) {
+	items := []models.OrderItem{{ProductID: "latte", Quantity: 2, UnitPrice: 4}, {ProductID: "muffin", Quantity: 1, UnitPrice: 3}}
+	standard := models.TaxRate{ID: "vat", Name: "VAT", Rate: 10, Active: true}
+	included := models.TaxRate{ID: "vat", Name: "VAT", Rate: 20, Inclusive: true, Active: true}
+	food := models.TaxRate{ID: "food", Name: "Food", Rate: 5, Categories: []string{"Food"}, Active: true}
+	latte := models.TaxRate{ID: "latte", Name: "Latte", Rate: 2, ProductIDs: []string{"latte"}, Active: true}
+	inactive := models.TaxRate{ID: "old", Name: "Old", Rate: 50, Active: false}
+
+	tests := []struct {
+		name     string
+		taxes    []models.TaxRate
+		discount float64
+		lines    []models.TaxLine
+		total    float64
+	}{
+		{name: "no rates", taxes: nil},
+		{name: "exclusive default rate", taxes: []models.TaxRate{standard},
+			lines: []models.TaxLine{{TaxID: "vat", Name: "VAT", Rate: 10, Taxable: 11, Amount: 1.1}}, total: 1.1},
+		{name: "inclusive default rate", taxes: []models.TaxRate{included},
+			lines: []models.TaxLine{{TaxID: "vat", Name: "VAT", Rate: 20, Inclusive: true, Taxable: 11, Amount: 1.83}}, total: 1.83},
+		{name: "category rate before the default", taxes: []models.TaxRate{standard, food},
+			lines: []models.TaxLine{
+				{TaxID: "vat", Name: "VAT", Rate: 10, Taxable: 8, Amount: 0.8},
+				{TaxID: "food", Name: "Food", Rate: 5, Taxable: 3, Amount: 0.15},
+			}, total: 0.95},
+		{name: "product rate before the category and the default", taxes: []models.TaxRate{standard, food, latte},
+			lines: []models.TaxLine{
+				{TaxID: "latte", Name: "Latte", Rate: 2, Taxable: 8, Amount: 0.16},
+				{TaxID: "food", Name: "Food", Rate: 5, Taxable: 3, Amount: 0.15},
+			}, total: 0.31},
+		{name: "discount spread over the lines", taxes: []models.TaxRate{standard, food}, discount: 2.2,
+			lines: []models.TaxLine{
+				{TaxID: "vat", Name: "VAT", Rate: 10, Taxable: 6.4, Amount: 0.64},
+				{TaxID: "food", Name: "Food", Rate: 5, Taxable: 2.4, Amount: 0.12},
+			}, total: 0.76},
+		{name: "inactive rates are skipped", taxes: []models.TaxRate{inactive}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useEmptyStorage(t)
+			writeStorage(t, "menu_items.json", testMenu)
+			writeStorage(t, "taxes.json", test.taxes)
+
+			order := models.Order{Items: items, Subtotal: 11, Discount: test.discount}
+			if err := NewTaxService().ApplyTaxes(&order); err != nil {
+				t.Fatalf("ApplyTaxes failed: %v", err)
+			}
+			if !reflect.DeepEqual(order.TaxLines, test.lines) {
+				t.Fatalf("got tax lines %+v, expected %+v", order.TaxLines, test.lines)
+			}
+			if order.TaxTotal != test.total {
+				t.Fatalf("got tax total %v, expected %v", order.TaxTotal, test.total)
+			}
+		})
+	}
+}
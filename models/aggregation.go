@@ -1,7 +1,13 @@
 package models
 
 type TotalSales struct {
-	Amount float64 `json:"total_sales"`
+	Amount       float64 `json:"total_sales"`
+	GrossSales   float64 `json:"gross_sales"`
+	Discounts    float64 `json:"discounts"`
+	Refunds      float64 `json:"refunds"`
+	NetSales     float64 `json:"net_sales"`
+	TaxCollected float64 `json:"tax_collected"`
+	NetRevenue   float64 `json:"net_revenue"`
 }
 
 type PopularItem struct {
@@ -12,3 +18,44 @@ type PopularItem struct {
 	Price       float64              `json:"price"`
 	Ingredients []MenuItemIngredient `json:"ingredients"`
 }
+
+type CustomerLifetimeValue struct {
+	CustomerID   int     `json:"customer_id"`
+	Name         string  `json:"name"`
+	OrdersCount  int     `json:"orders_count"`
+	TotalSpent   float64 `json:"total_spent"`
+	FirstOrderAt string  `json:"first_order_at"`
+	LastOrderAt  string  `json:"last_order_at"`
+}
+
+type PaymentMethodSummary struct {
+	Method   string  `json:"method"`
+	Count    int     `json:"count"`
+	Amount   float64 `json:"amount"`
+	Tips     float64 `json:"tips"`
+	Tendered float64 `json:"tendered"`
+	Change   float64 `json:"change"`
+}
+
+// StaffSales sums up the orders a member of staff took and the closed orders they were paid for.
+// Orders made without a staff key are reported with staff ID 0.
+type StaffSales struct {
+	StaffID      int     `json:"staff_id"`
+	Name         string  `json:"name"`
+	OrdersTaken  int     `json:"orders_taken"`
+	OrdersClosed int     `json:"orders_closed"`
+	Sales        float64 `json:"sales"`
+	Tips         float64 `json:"tips"`
+}
+
+// ShiftSales sums up the orders closed during a shift
+type ShiftSales struct {
+	ShiftID      int     `json:"shift_id"`
+	StaffID      int     `json:"staff_id"`
+	Name         string  `json:"name"`
+	ClockIn      string  `json:"clock_in"`
+	ClockOut     string  `json:"clock_out,omitempty"`
+	OrdersClosed int     `json:"orders_closed"`
+	Sales        float64 `json:"sales"`
+	Tips         float64 `json:"tips"`
+}
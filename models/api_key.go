@@ -0,0 +1,18 @@
+package models
+
+// APIKey lets a member of staff call the API with the permissions of its role.
+// Only the SHA-256 hash of the key is stored; the key itself is shown once when it is issued.
+type APIKey struct {
+	ID        string `json:"key_id"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	StaffID   int    `json:"staff_id,omitempty"`
+	Hash      string `json:"hash,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// IssuedAPIKey is the answer to issuing a key, the only time the key is readable
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
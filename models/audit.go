@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records one change made through the API. Entries are only ever appended.
+type AuditEntry struct {
+	ID        int             `json:"audit_id"`
+	Timestamp string          `json:"timestamp"`
+	RequestID string          `json:"request_id,omitempty"`
+	Actor     AuditActor      `json:"actor"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Changes   []AuditChange   `json:"changes"`
+}
+
+// AuditActor is the API key a change was made with
+type AuditActor struct {
+	KeyID   string `json:"key_id"`
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+	StaffID int    `json:"staff_id,omitempty"`
+}
+
+// AuditChange is a field whose value differs between the before and after states,
+// named by its JSON path such as items[0].quantity
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// AuditFilter describes which entries GET /audit should return
+type AuditFilter struct {
+	Entity    string
+	EntityID  string
+	Action    string
+	KeyID     string
+	StaffID   int
+	RequestID string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
@@ -0,0 +1,10 @@
+package models
+
+type Customer struct {
+	ID        int    `json:"customer_id"`
+	Name      string `json:"name"`
+	Phone     string `json:"phone"`
+	Email     string `json:"email"`
+	Notes     string `json:"notes"`
+	CreatedAt string `json:"created_at"`
+}
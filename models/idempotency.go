@@ -0,0 +1,20 @@
+package models
+
+import "encoding/json"
+
+// IdempotencyKey remembers the response of a request so that a retry with the same key
+// gets the same response instead of creating the resource again. Keys belong to the API key
+// that sent them, named by Principal, so that clients cannot see each other's responses.
+type IdempotencyKey struct {
+	Principal   string          `json:"principal"`
+	Key         string          `json:"key"`
+	RequestHash string          `json:"request_hash"`
+	OrderID     int             `json:"order_id"`
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type"`
+	Location    string          `json:"location,omitempty"`
+	ETag        string          `json:"etag,omitempty"`
+	Response    json.RawMessage `json:"response"`
+	CreatedAt   string          `json:"created_at"`
+	ExpiresAt   string          `json:"expires_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// ImportResult reports what a bulk import did or, on a dry run, would do
+type ImportResult struct {
+	Mode      string        `json:"mode"`
+	DryRun    bool          `json:"dry_run"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Deleted   int           `json:"deleted"`
+	Errors    []ImportError `json:"errors,omitempty"`
+}
+
+// ImportError is the problem found in one row of an import, counted from 1
+type ImportError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
@@ -5,4 +5,8 @@ type InventoryItem struct {
 	Name         string  `json:"name"`
 	Quantity     float64 `json:"quantity"`
 	Unit         string  `json:"unit"`
+	Version      int     `json:"version"`
+	UpdatedBy    int     `json:"updated_by,omitempty"`
+	Archived     bool    `json:"archived,omitempty"`
+	ArchivedAt   string  `json:"archived_at,omitempty"`
 }
@@ -0,0 +1,57 @@
+package models
+
+// LoyaltyProgram holds the configurable earn rules and the rewards customers can redeem
+type LoyaltyProgram struct {
+	EarnRules []EarnRule `json:"earn_rules"`
+	Rewards   []Reward   `json:"rewards"`
+}
+
+// EarnRule credits points per currency unit spent ("points_per_currency")
+// or stamps per qualifying item ("stamp_per_item")
+type EarnRule struct {
+	ID         string   `json:"rule_id"`
+	Type       string   `json:"type"`
+	Rate       float64  `json:"rate"`
+	ProductIDs []string `json:"product_ids,omitempty"`
+	Active     bool     `json:"active"`
+}
+
+// Reward is paid for with points or stamps and gives either a free item ("free_item"),
+// a percentage ("percent_discount") or a fixed amount ("fixed_discount") off an order
+type Reward struct {
+	ID        string  `json:"reward_id"`
+	Name      string  `json:"name"`
+	Currency  string  `json:"currency"`
+	Cost      int     `json:"cost"`
+	Type      string  `json:"type"`
+	ProductID string  `json:"product_id,omitempty"`
+	Value     float64 `json:"value,omitempty"`
+	Active    bool    `json:"active"`
+}
+
+// AppliedReward is a reward on an order. TransactionID is the redeem transaction that paid for it,
+// so that cancelling the order gives back exactly that, whatever became of the order ID.
+type AppliedReward struct {
+	RewardID      string  `json:"reward_id"`
+	Discount      float64 `json:"discount"`
+	TransactionID int     `json:"transaction_id,omitempty"`
+}
+
+type LoyaltyTransaction struct {
+	ID         int    `json:"transaction_id"`
+	CustomerID int    `json:"customer_id"`
+	OrderID    int    `json:"order_id"`
+	Type       string `json:"type"`
+	Points     int    `json:"points"`
+	Stamps     int    `json:"stamps"`
+	RuleID     string `json:"rule_id,omitempty"`
+	RewardID   string `json:"reward_id,omitempty"`
+	ReversesID int    `json:"reverses_transaction_id,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type LoyaltyBalance struct {
+	CustomerID int `json:"customer_id"`
+	Points     int `json:"points"`
+	Stamps     int `json:"stamps"`
+}
@@ -4,8 +4,13 @@ type MenuItem struct {
 	ID          string               `json:"product_id"`
 	Name        string               `json:"name"`
 	Description string               `json:"description"`
+	Category    string               `json:"category,omitempty"`
 	Price       float64              `json:"price"`
+	PrepSeconds int                  `json:"prep_seconds,omitempty"`
 	Ingredients []MenuItemIngredient `json:"ingredients"`
+	Version     int                  `json:"version"`
+	Archived    bool                 `json:"archived,omitempty"`
+	ArchivedAt  string               `json:"archived_at,omitempty"`
 }
 
 type MenuItemIngredient struct {
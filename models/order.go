@@ -1,14 +1,50 @@
 package models
 
+import "time"
+
 type Order struct {
 	ID           int         `json:"order_id"`
+	CustomerID   int         `json:"customer_id,omitempty"`
 	CustomerName string      `json:"customer_name"`
 	Items        []OrderItem `json:"items"`
 	Status       string      `json:"status"`
 	CreatedAt    string      `json:"created_at"`
+	// EstimatedReadyAt is computed from the queue when the order is read and never stored
+	EstimatedReadyAt string             `json:"estimated_ready_at,omitempty"`
+	PromoCode        string             `json:"promo_code,omitempty"`
+	Promotions       []AppliedPromotion `json:"promotions,omitempty"`
+	Rewards          []AppliedReward    `json:"rewards,omitempty"`
+	Subtotal         float64            `json:"subtotal"`
+	Discount         float64            `json:"discount"`
+	TaxLines         []TaxLine          `json:"tax_lines,omitempty"`
+	TaxTotal         float64            `json:"tax_total"`
+	Total            float64            `json:"total"`
+	Payments         []Payment          `json:"payments,omitempty"`
+	AmountPaid       float64            `json:"amount_paid"`
+	Refunds          []Refund           `json:"refunds,omitempty"`
+	Refunded         float64            `json:"amount_refunded"`
+	CreatedBy        int                `json:"created_by,omitempty"`
+	ClosedBy         int                `json:"closed_by,omitempty"`
+	ClosedShiftID    int                `json:"closed_shift_id,omitempty"`
+	Version          int                `json:"version"`
 }
 
 type OrderItem struct {
-	ProductID string `json:"product_id"`
-	Quantity  int    `json:"quantity"`
+	ProductID string   `json:"product_id"`
+	Quantity  int      `json:"quantity"`
+	UnitPrice float64  `json:"unit_price"`
+	Modifiers []string `json:"modifiers,omitempty"`
+	Status    string   `json:"status,omitempty"`
+}
+
+// OrderFilter describes which orders GET /orders should return and in what order
+type OrderFilter struct {
+	Status     string
+	Customer   string
+	CustomerID int
+	From       time.Time
+	To         time.Time
+	Sort       string
+	Limit      int
+	Offset     int
 }
@@ -0,0 +1,14 @@
+package models
+
+// Payment is one tender against an order. Amount is what goes towards the order total,
+// Tendered is the cash handed over and Change what was given back. Tips are kept apart.
+type Payment struct {
+	ID        int     `json:"payment_id"`
+	Method    string  `json:"method"`
+	Amount    float64 `json:"amount"`
+	Tendered  float64 `json:"tendered,omitempty"`
+	Change    float64 `json:"change,omitempty"`
+	Tip       float64 `json:"tip,omitempty"`
+	Reference string  `json:"reference,omitempty"`
+	CreatedAt string  `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+// Promotion is a discount rule evaluated against the lines of an order.
+// Type is "percentage", "fixed" or "buy_x_get_y". ProductIDs and Categories limit the
+// lines it applies to, Code makes it apply only when the order carries that promo code,
+// and StartTime/EndTime ("15:04") and Days ("mon".."sun") restrict when it is valid.
+type Promotion struct {
+	ID          string   `json:"promotion_id"`
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Value       float64  `json:"value,omitempty"`
+	Code        string   `json:"code,omitempty"`
+	ProductIDs  []string `json:"product_ids,omitempty"`
+	Categories  []string `json:"categories,omitempty"`
+	BuyQuantity int      `json:"buy_quantity,omitempty"`
+	GetQuantity int      `json:"get_quantity,omitempty"`
+	StartTime   string   `json:"start_time,omitempty"`
+	EndTime     string   `json:"end_time,omitempty"`
+	Days        []string `json:"days,omitempty"`
+	Active      bool     `json:"active"`
+	Version     int      `json:"version"`
+}
+
+type AppliedPromotion struct {
+	PromotionID string  `json:"promotion_id"`
+	Name        string  `json:"name"`
+	Discount    float64 `json:"discount"`
+}
@@ -0,0 +1,27 @@
+package models
+
+type QueueEntry struct {
+	Position         int         `json:"position"`
+	OrderID          int         `json:"order_id"`
+	CustomerName     string      `json:"customer_name"`
+	Status           string      `json:"status"`
+	CreatedAt        string      `json:"created_at"`
+	EstimatedReadyAt string      `json:"estimated_ready_at,omitempty"`
+	Items            []QueueItem `json:"items"`
+}
+
+type QueueItem struct {
+	ProductID string   `json:"product_id"`
+	Name      string   `json:"name"`
+	Quantity  int      `json:"quantity"`
+	Modifiers []string `json:"modifiers,omitempty"`
+	Status    string   `json:"status"`
+}
+
+// OrderEvent is pushed to the barista display whenever an order changes
+type OrderEvent struct {
+	Type    string `json:"type"`
+	OrderID int    `json:"order_id"`
+	Order   Order  `json:"order"`
+	At      string `json:"at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// Refund gives back part of a closed order. Amount is what the customer gets back,
+// Tax the part of it that was tax.
+type Refund struct {
+	ID        int          `json:"refund_id"`
+	Items     []RefundItem `json:"items"`
+	Reason    string       `json:"reason"`
+	Restock   bool         `json:"restock"`
+	Amount    float64      `json:"amount"`
+	Tax       float64      `json:"tax"`
+	CreatedAt string       `json:"created_at"`
+}
+
+type RefundItem struct {
+	ProductID string  `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	Amount    float64 `json:"amount"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// Staff is an employee of the shop. API keys issued for a member of staff attribute the
+// orders and inventory changes made with them to that member.
+type Staff struct {
+	ID        int    `json:"staff_id"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	Active    bool   `json:"active"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Shift is the time between clocking in and clocking out. An open shift has no ClockOut.
+type Shift struct {
+	ID       int    `json:"shift_id"`
+	StaffID  int    `json:"staff_id"`
+	ClockIn  string `json:"clock_in"`
+	ClockOut string `json:"clock_out,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// TaxRate is a percentage applied to the order lines of the listed products or menu
+// categories. A rate without products and categories applies to every other line.
+// Inclusive rates are already part of the menu price, exclusive ones are added on top.
+type TaxRate struct {
+	ID         string   `json:"tax_id"`
+	Name       string   `json:"name"`
+	Rate       float64  `json:"rate"`
+	ProductIDs []string `json:"product_ids,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Inclusive  bool     `json:"inclusive"`
+	Active     bool     `json:"active"`
+	Version    int      `json:"version"`
+}
+
+type TaxLine struct {
+	TaxID     string  `json:"tax_id"`
+	Name      string  `json:"name"`
+	Rate      float64 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+	Taxable   float64 `json:"taxable"`
+	Amount    float64 `json:"amount"`
+}